@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type AtomFeed struct {
+	Base     string      `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title    AtomText    `xml:"title"`
+	Subtitle AtomText    `xml:"subtitle"`
+	Links    []AtomLink  `xml:"link"`
+	Entries  []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	Base      string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	ID        string     `xml:"id"`
+	Title     AtomText   `xml:"title"`
+	Links     []AtomLink `xml:"link"`
+	Summary   AtomText   `xml:"summary"`
+	Content   AtomText   `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Categories []struct {
+		Term  string `xml:"term,attr"`
+		Label string `xml:"label,attr"`
+	} `xml:"category"`
+}
+
+type AtomLink struct {
+	Base   string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// AtomText holds a text construct, which can be plain text, escaped html or inline xhtml.
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// HTML is the construct as html, for summaries and contents. Plain text, the default,
+// is escaped so that anything looking like markup is shown as written.
+func (t AtomText) HTML() string {
+	switch t.Type {
+	case "html", "xhtml":
+		return t.String()
+	}
+	return textToHTML(t.Text)
+}
+
+// PlainText is the construct as text, for titles. html and xhtml titles lose their markup.
+func (t AtomText) PlainText() string {
+	if t.Type == "html" || t.Type == "xhtml" {
+		return htmlToText(t.String())
+	}
+	return t.String()
+}
+
+// alternateLink picks the rel="alternate" link, which is also the default when rel is missing.
+// The link is resolved against base, the xml:base in scope.
+func alternateLink(links []AtomLink, base string) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.resolve(base)
+		}
+	}
+	if len(links) > 0 {
+		return links[0].resolve(base)
+	}
+	return ""
+}
+
+// enclosureLinks maps rel="enclosure" links, which is how Atom podcasts attach media.
+func enclosureLinks(links []AtomLink, base string) []RSSEnclosure {
+	var enclosures []RSSEnclosure
+	for _, link := range links {
+		if link.Rel == "enclosure" {
+			enclosures = append(enclosures, RSSEnclosure{URL: link.resolve(base), Type: link.Type, Length: link.Length})
+		}
+	}
+	return enclosures
+}
+
+func (link AtomLink) resolve(base string) string {
+	return resolveURL(resolveURL(base, link.Base), link.Href)
+}
+
+// resolveURL resolves ref against base. Either can be relative, the rest is done against
+// the feed URL by ResolveLinks.
+func resolveURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == "" {
+		return ref
+	}
+	b, err := url.Parse(strings.TrimSpace(base))
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// parseAtom decodes an Atom document and maps it onto RSSFeed so the rest of gator
+// only has to deal with one item model.
+func parseAtom(decoder *xml.Decoder, start xml.StartElement) (*RSSFeed, error) {
+	var atom AtomFeed
+	err := decoder.DecodeElement(&atom, &start)
+	warning, err := salvageError(decoder, err, len(atom.Entries))
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling atom: %w", err)
+	}
+
+	var feed RSSFeed
+	if warning != "" {
+		feed.Warnings = append(feed.Warnings, warning)
+	}
+	feed.Channel.Title = atom.Title.PlainText()
+	feed.Channel.Link = alternateLink(atom.Links, atom.Base)
+	feed.Channel.Description = atom.Subtitle.PlainText()
+
+	for _, entry := range atom.Entries {
+		base := resolveURL(atom.Base, entry.Base)
+		content := entry.Content.HTML()
+		description := entry.Summary.HTML()
+		if description == "" {
+			description = content
+		}
+
+		date := entry.Published
+		if date == "" {
+			date = entry.Updated
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       entry.Title.PlainText(),
+			Link:        alternateLink(entry.Links, base),
+			Description: description,
+			PubDate:     rfc3339ToRSSDate(date),
+			Author:      entry.authorNames(),
+			GUID:        strings.TrimSpace(entry.ID),
+			Enclosures:  enclosureLinks(entry.Links, base),
+			Content:     content,
+			Categories:  entry.categories(),
+		})
+	}
+
+	return &feed, nil
+}
+
+// w3cDateLayouts covers RFC 3339 and the shorter W3C-DTF profiles used by dc:date.
+var w3cDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// rfc3339ToRSSDate converts an RFC 3339 (or W3C-DTF) timestamp to the RSS date format,
+// leaving it untouched if it can't be parsed.
+func rfc3339ToRSSDate(date string) string {
+	date = strings.TrimSpace(date)
+	for _, layout := range w3cDateLayouts {
+		t, err := time.Parse(layout, date)
+		if err == nil {
+			return t.Format(time.RFC1123Z)
+		}
+	}
+	return date
+}
+
+func (entry AtomEntry) authorNames() string {
+	var names []string
+	for _, author := range entry.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// categories prefers the human readable label over the term.
+func (entry AtomEntry) categories() []string {
+	var categories []string
+	for _, category := range entry.Categories {
+		if category.Label != "" {
+			categories = append(categories, category.Label)
+		} else {
+			categories = append(categories, category.Term)
+		}
+	}
+	return categories
+}
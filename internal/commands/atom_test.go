@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseFeedString parses doc as a feed served with contentType and fails the test on errors.
+func parseFeedString(t *testing.T, doc, contentType string) *RSSFeed {
+	t.Helper()
+	feed, err := ParseFeed(strings.NewReader(doc), contentType)
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	return feed
+}
+
+func TestParseAtom(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>Site</title>
+<subtitle>All the posts</subtitle>
+<link rel="self" href="http://site/feed.atom"/>
+<link href="http://site/"/>
+<entry>
+<title>First</title>
+<link rel="alternate" href="http://site/1"/>
+<summary>Summary</summary>
+<content type="html">Content</content>
+<published>2006-01-02T15:04:05Z</published>
+<updated>2006-01-03T15:04:05Z</updated>
+</entry>
+<entry>
+<title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Second</div></title>
+<link rel="related" href="http://elsewhere/"/>
+<content>Only content</content>
+<updated>2006-01-03T15:04:05+01:00</updated>
+</entry>
+</feed>`
+
+	feed := parseFeedString(t, doc, "application/atom+xml")
+	if feed.Channel.Title != "Site" || feed.Channel.Link != "http://site/" || feed.Channel.Description != "All the posts" {
+		t.Errorf("channel = %q, %q, %q", feed.Channel.Title, feed.Channel.Link, feed.Channel.Description)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Item))
+	}
+
+	first := feed.Channel.Item[0]
+	if first.Title != "First" || first.Link != "http://site/1" || first.Description != "<p>Summary</p>" {
+		t.Errorf("first item = %+v", first)
+	}
+	if first.PubDate != "Mon, 02 Jan 2006 15:04:05 +0000" {
+		t.Errorf("first pubDate = %q, want the published date", first.PubDate)
+	}
+
+	second := feed.Channel.Item[1]
+	if second.Link != "http://elsewhere/" || second.Description != "<p>Only content</p>" {
+		t.Errorf("second item = %+v", second)
+	}
+	if second.PubDate != "Tue, 03 Jan 2006 15:04:05 +0100" {
+		t.Errorf("second pubDate = %q, want the updated date", second.PubDate)
+	}
+}
+
+func TestAtomTextHTML(t *testing.T) {
+	tests := []struct {
+		text AtomText
+		want string
+	}{
+		{AtomText{Text: "a <b> & c"}, "<p>a &lt;b&gt; &amp; c</p>"},
+		{AtomText{Type: "text", Text: "one\n\ntwo"}, "<p>one</p>\n<p>two</p>"},
+		{AtomText{Type: "html", Text: " <b>bold</b> "}, "<b>bold</b>"},
+		{AtomText{Type: "xhtml", Text: "bold", Inner: "<div><b>bold</b></div>"}, "<div><b>bold</b></div>"},
+		{AtomText{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.text.HTML(); got != tt.want {
+			t.Errorf("%+v.HTML() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// utf8Reader converts the document to UTF-8 when its encoding is known up front. A byte
+// order mark wins, then the charset in the HTTP Content-Type. It reports whether it
+// converted anything; if not, the XML declaration decides (see xmlCharsetReader).
+func utf8Reader(br *bufio.Reader, contentType string) (io.Reader, bool) {
+	if hasBOM(br) {
+		// BOMOverride decodes according to the BOM and drops it
+		return transform.NewReader(br, unicode.BOMOverride(encoding.Nop.NewDecoder())), true
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return br, false
+	}
+	enc, name := charset.Lookup(params["charset"])
+	if enc == nil || name == "utf-8" {
+		return br, false
+	}
+	return enc.NewDecoder().Reader(br), true
+}
+
+func hasBOM(br *bufio.Reader) bool {
+	peeked, _ := br.Peek(3)
+	return bytes.HasPrefix(peeked, []byte{0xEF, 0xBB, 0xBF}) ||
+		bytes.HasPrefix(peeked, []byte{0xFE, 0xFF}) ||
+		bytes.HasPrefix(peeked, []byte{0xFF, 0xFE})
+}
+
+// xmlCharsetReader handles the encoding="..." of the XML declaration. Once utf8Reader
+// has converted the document the declaration is stale and has to be ignored.
+func xmlCharsetReader(converted bool) func(string, io.Reader) (io.Reader, error) {
+	return func(label string, input io.Reader) (io.Reader, error) {
+		if converted {
+			return input, nil
+		}
+		r, err := charset.NewReaderLabel(label, input)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported encoding %q: %w", label, err)
+		}
+		return r, nil
+	}
+}
@@ -3,7 +3,9 @@ package commands
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/boxy-pug/gator/internal/config"
@@ -16,34 +18,34 @@ type Command struct {
 }
 
 type Commands struct {
-	handlers map[string]func(*config.State, Command) error
+	handlers map[string]func(context.Context, *config.State, Command) error
 }
 
 // NewCommands creates and returns a new Commands instance.
 func NewCommands() *Commands {
 	return &Commands{
-		handlers: make(map[string]func(*config.State, Command) error),
+		handlers: make(map[string]func(context.Context, *config.State, Command) error),
 	}
 }
 
 // This method registers a new handler function for a Command name.
-func (c *Commands) Register(name string, handler func(*config.State, Command) error) {
+func (c *Commands) Register(name string, handler func(context.Context, *config.State, Command) error) {
 	c.handlers[name] = handler
 }
 
 // This method runs a given Command with the provided state if it exists.
-func (c *Commands) Run(s *config.State, cmd Command) error {
+func (c *Commands) Run(ctx context.Context, s *config.State, cmd Command) error {
 	handler, exists := c.handlers[cmd.Name]
 	if !exists {
 		return errors.New("command not found")
 	}
-	return handler(s, cmd)
+	return handler(ctx, s, cmd)
 }
 
 // HandlerReset deletes all users from the database.
-func HandlerReset(s *config.State, cmd Command) error {
+func HandlerReset(ctx context.Context, s *config.State, cmd Command) error {
 	// Execute the DeleteAllUsers query
-	err := s.Db.DeleteAllUsers(context.Background())
+	err := s.Db.DeleteAllUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("error resetting database: %v", err)
 	}
@@ -52,8 +54,8 @@ func HandlerReset(s *config.State, cmd Command) error {
 	return nil
 }
 
-func HandlerUsers(s *config.State, cmd Command) error {
-	users, err := s.Db.GetUsers(context.Background())
+func HandlerUsers(ctx context.Context, s *config.State, cmd Command) error {
+	users, err := s.Db.GetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("error fetching users")
 	}
@@ -69,8 +71,9 @@ func HandlerUsers(s *config.State, cmd Command) error {
 
 }
 
-// HandlerAgg fetches an RSS feed and prints it .
-func HandlerAgg(s *config.State, cmd Command) error {
+// HandlerAgg scrapes feeds on an interval. Each tick claims up to --batch feeds
+// for --lease and fetches them with --workers concurrent workers.
+func HandlerAgg(ctx context.Context, s *config.State, cmd Command) error {
 	if len(cmd.Args) < 1 {
 		return fmt.Errorf("expected time between req argument")
 	}
@@ -80,22 +83,97 @@ func HandlerAgg(s *config.State, cmd Command) error {
 		return fmt.Errorf("could not parse duration: %w", err)
 	}
 
-	fmt.Printf("Collecting feeds every %s", timeBetweenRequests)
+	flags := flag.NewFlagSet("agg", flag.ContinueOnError)
+	workers := flags.Int("workers", 1, "number of feeds fetched concurrently")
+	batch := flags.Int("batch", 1, "number of feeds claimed per tick")
+	lease := flags.Duration("lease", 5*time.Minute, "how long a claimed feed stays reserved for this process")
+	maxFailures := flags.Int("max-failures", 10, "consecutive failures before a feed is disabled")
+	download := flags.Bool("download", false, "download new episodes of the current user's podcasts after each tick")
+	err = flags.Parse(cmd.Args[1:])
+	if err != nil {
+		return fmt.Errorf("could not parse agg flags: %w", err)
+	}
+	if *workers < 1 || *batch < 1 {
+		return fmt.Errorf("--workers and --batch must be at least 1")
+	}
+	if *lease <= 0 {
+		return fmt.Errorf("--lease must be positive")
+	}
+	if *maxFailures < 1 {
+		return fmt.Errorf("--max-failures must be at least 1")
+	}
+
+	fmt.Printf("Collecting %d feed(s) every %s with %d worker(s)\n", *batch, timeBetweenRequests, *workers)
+
+	fetcher, err := NewFetcher(s.Config)
+	if err != nil {
+		return err
+	}
+	// the lease is renewed before each fetch, so it has to outlast one fetch
+	if *lease < fetcher.client.Timeout {
+		return fmt.Errorf("--lease must be at least fetch_total_timeout (%s)", fetcher.client.Timeout)
+	}
+
+	opts := ScrapeOptions{
+		Fetcher:     fetcher,
+		Workers:     *workers,
+		Batch:       *batch,
+		Lease:       *lease,
+		MaxFailures: *maxFailures,
+	}
+
+	// downloads are for the feeds the logged in user follows
+	var downloadUser database.User
+	var downloadOpts DownloadOptions
+	if *download {
+		downloadUser, err = s.Db.GetUser(ctx, s.Config.CurrentUserName)
+		if err != nil {
+			return fmt.Errorf("--download needs a logged in user: %w", err)
+		}
+		downloadOpts, err = DownloadOptionsFromConfig(s.Config, fetcher)
+		if err != nil {
+			return err
+		}
+	}
 
 	ticker := time.NewTicker(timeBetweenRequests)
 	defer ticker.Stop()
 
-	for ; ; <-ticker.C {
-		ScrapeFeeds(s)
+	var total ScrapeStats
+	ticks := 0
+	for {
+		stats, err := ScrapeFeeds(ctx, s, opts)
+		total.Add(stats)
+		ticks++
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error scraping feeds: %v", err)
+		}
+		if *download && ctx.Err() == nil {
+			downloads, err := DownloadEnclosures(ctx, s, downloadUser, downloadOpts)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error downloading enclosures: %v", err)
+			}
+			if downloads.Downloaded > 0 || downloads.Pruned > 0 {
+				log.Printf("Downloaded %d enclosure(s), deleted %d.", downloads.Downloaded, downloads.Pruned)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Shutting down after %d tick(s): %d feed(s) scraped, %d failed, %d new post(s), %d updated post(s)\n",
+				ticks, total.Feeds, total.Failed, total.NewPosts, total.UpdatedPosts)
+			return nil
+		case <-ticker.C:
+		}
 	}
 }
 
-func MiddleWareLoggedIn(handler func(s *config.State, cmd Command, user database.User) error) func(*config.State, Command) error {
-	return func(s *config.State, cmd Command) error {
-		user, err := s.Db.GetUser(context.Background(), s.Config.CurrentUserName)
+func MiddleWareLoggedIn(handler func(ctx context.Context, s *config.State, cmd Command, user database.User) error) func(context.Context, *config.State, Command) error {
+	return func(ctx context.Context, s *config.State, cmd Command) error {
+		user, err := s.Db.GetUser(ctx, s.Config.CurrentUserName)
 		if err != nil {
 			return fmt.Errorf("user not logged in or does not exist: %w", err)
 		}
-		return handler(s, cmd, user)
+		return handler(ctx, s, cmd, user)
 	}
 }
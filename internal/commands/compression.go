@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is sent with every fetch. Setting it ourselves turns off the transparent
+// gzip handling in net/http, so decodeBody has to undo whatever the server picked.
+const acceptEncoding = "br, gzip, deflate"
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeBody undoes the Content-Encoding of the response. It returns the encoding it
+// decoded, or "" for an uncompressed body. The caller closes the reader, which doesn't
+// close body.
+func decodeBody(resp *http.Response, body io.Reader) (io.ReadCloser, string, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return io.NopCloser(body), "", nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid gzip body: %w", err)
+		}
+		return r, "gzip", nil
+	case "deflate":
+		r, err := deflateReader(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid deflate body: %w", err)
+		}
+		return r, "deflate", nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), "br", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// deflateReader handles both forms of "deflate" found in the wild: zlib-wrapped as the
+// spec says, and the raw deflate stream some servers send instead.
+func deflateReader(body io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	header, _ := br.Peek(2)
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dateLayouts are tried in order after the date has been normalized, so they only
+// need English month abbreviations, numeric zones and no weekday.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 06 15:04:05",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 15:04:05 2006 -0700",
+	"Jan 2 15:04:05 2006",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 15:04",
+	"Jan 2 2006",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// zoneOffsets maps the zone names seen in the wild to offsets. time.Parse would accept
+// the names but treat anything it doesn't know as UTC.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// monthNames maps English and common localized month names (lowercased, without dots)
+// to the abbreviations time.Parse expects.
+var monthNames = map[string]string{
+	"january": "Jan", "february": "Feb", "march": "Mar", "april": "Apr", "june": "Jun",
+	"july": "Jul", "august": "Aug", "september": "Sep", "sept": "Sep", "october": "Oct",
+	"november": "Nov", "december": "Dec",
+	// German
+	"januar": "Jan", "jän": "Jan", "februar": "Feb", "märz": "Mar", "mär": "Mar", "mrz": "Mar",
+	"mai": "May", "juni": "Jun", "juli": "Jul", "okt": "Oct", "oktober": "Oct", "dez": "Dec",
+	"dezember": "Dec",
+	// French
+	"janvier": "Jan", "janv": "Jan", "février": "Feb", "févr": "Feb", "fév": "Feb", "mars": "Mar",
+	"avril": "Apr", "avr": "Apr", "juin": "Jun", "juillet": "Jul", "juil": "Jul", "août": "Aug",
+	"septembre": "Sep", "octobre": "Oct", "novembre": "Nov", "décembre": "Dec", "déc": "Dec",
+	// Spanish
+	"enero": "Jan", "ene": "Jan", "febrero": "Feb", "marzo": "Mar", "abril": "Apr", "abr": "Apr",
+	"mayo": "May", "junio": "Jun", "julio": "Jul", "agosto": "Aug", "ago": "Aug",
+	"septiembre": "Sep", "setiembre": "Sep", "octubre": "Oct", "noviembre": "Nov",
+	"diciembre": "Dec", "dic": "Dec",
+	// Dutch and Italian leftovers
+	"januari": "Jan", "februari": "Feb", "maart": "Mar", "mei": "May", "augustus": "Aug",
+	"gennaio": "Jan", "febbraio": "Feb", "aprile": "Apr", "maggio": "May", "mag": "May",
+	"giugno": "Jun", "giu": "Jun", "luglio": "Jul", "lug": "Jul", "settembre": "Sep",
+	"set": "Sep", "ottobre": "Oct", "ott": "Oct", "dicembre": "Dec",
+}
+
+// weekdayNames are dropped before parsing; the weekday adds nothing and is often wrong or localized.
+var weekdayNames = map[string]bool{
+	"mon": true, "tue": true, "tues": true, "wed": true, "thu": true, "thur": true, "thurs": true,
+	"fri": true, "sat": true, "sun": true, "monday": true, "tuesday": true, "wednesday": true,
+	"thursday": true, "friday": true, "saturday": true, "sunday": true,
+	// German
+	"mo": true, "di": true, "mi": true, "do": true, "fr": true, "sa": true, "so": true,
+	"montag": true, "dienstag": true, "mittwoch": true, "donnerstag": true, "freitag": true,
+	"samstag": true, "sonntag": true,
+	// French
+	"lun": true, "mar": true, "mer": true, "jeu": true, "ven": true, "sam": true, "dim": true,
+	"lundi": true, "mardi": true, "mercredi": true, "jeudi": true, "vendredi": true,
+	"samedi": true, "dimanche": true,
+	// Spanish
+	"lunes": true, "martes": true, "miércoles": true, "jueves": true, "viernes": true,
+	"sábado": true, "domingo": true,
+}
+
+var dateCommentRegex = regexp.MustCompile(`\([^)]*\)`)
+
+// ParsePublishedDate parses the many date formats found in feeds. The input is
+// normalized first (weekday dropped, month and zone names translated) and then
+// matched against dateLayouts.
+func ParsePublishedDate(raw string) (time.Time, error) {
+	normalized := normalizeDate(raw)
+	if normalized == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, normalized)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format: %q", raw)
+}
+
+func normalizeDate(raw string) string {
+	// "+0000 (UTC)" style trailing comments
+	raw = dateCommentRegex.ReplaceAllString(raw, " ")
+	fields := strings.Fields(raw)
+
+	var tokens []string
+	for i, field := range fields {
+		word := strings.ToLower(strings.Trim(field, ".,"))
+
+		// time.Time.String output: "+0000 UTC m=+0.000000001", where the zone name
+		// repeats the offset and the monotonic clock reading means nothing here
+		if strings.HasPrefix(word, "m=") {
+			continue
+		}
+		if len(tokens) > 0 && isNumericOffset(tokens[len(tokens)-1]) && isZoneName(field) {
+			continue
+		}
+
+		// a weekday can only lead the date, and "Mar 2 2024" is still March unless followed by a comma
+		if i == 0 && weekdayNames[word] && (strings.HasSuffix(field, ",") || !isEnglishMonth(word)) {
+			continue
+		}
+		if month, ok := monthNames[word]; ok {
+			tokens = append(tokens, month)
+			continue
+		}
+		if isEnglishMonth(word) {
+			tokens = append(tokens, strings.ToUpper(word[:1])+word[1:])
+			continue
+		}
+		// "1st", "22nd"
+		if trimmed := strings.TrimRight(word, "stndrh"); trimmed != word && isDigits(trimmed) {
+			tokens = append(tokens, trimmed)
+			continue
+		}
+		if offset, ok := zoneOffsets[strings.ToUpper(word)]; ok && i > 0 {
+			tokens = append(tokens, offset)
+			continue
+		}
+		tokens = append(tokens, strings.TrimRight(field, ","))
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// isNumericOffset reports whether token is a zone offset like "+0100" or "-05:00".
+func isNumericOffset(token string) bool {
+	if len(token) < 3 || (token[0] != '+' && token[0] != '-') {
+		return false
+	}
+	return isDigits(strings.ReplaceAll(token[1:], ":", ""))
+}
+
+// isZoneName reports whether field looks like a zone abbreviation such as "CET", or
+// the "+03" Go prints for zones without one.
+func isZoneName(field string) bool {
+	if isNumericOffset(field) {
+		return len(field) == 3
+	}
+	for _, r := range field {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return len(field) >= 2
+}
+
+func isEnglishMonth(word string) bool {
+	switch word {
+	case "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec":
+		return true
+	}
+	return false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePublishedDate(t *testing.T) {
+	utc := time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		raw  string
+		want time.Time
+	}{
+		// RFC 1123 and its variants
+		{"Tue, 05 Mar 2024 14:30:00 +0000", utc},
+		{"Tue, 05 Mar 2024 14:30:00 GMT", utc},
+		{"Tue, 5 Mar 2024 14:30:00 UT", utc},
+		{"Tue, 05 Mar 2024 09:30:00 EST", utc},
+		{"Tue, 05 Mar 2024 15:30:00 +01:00", utc},
+		{"05 Mar 2024 14:30 +0000", utc},
+		{"Tue, 05 Mar 24 14:30:00 +0000", utc},
+		{"Tuesday, 05 March 2024 14:30:00 +0000", utc},
+		{"Tue, 05 Mar 2024 14:30:00 +0000 (UTC)", utc},
+		// ISO 8601 and RFC 3339
+		{"2024-03-05T14:30:00Z", utc},
+		{"2024-03-05T14:30:00.000Z", utc},
+		{"2024-03-05T16:30:00+02:00", utc},
+		{"2024-03-05T14:30Z", utc},
+		{"2024-03-05T14:30:00", utc},
+		{"2024-03-05 14:30:00 +0000", utc},
+		{"2024-03-05 14:30:00", utc},
+		{"2024-03-05 14:30", utc},
+		{"2024/03/05 14:30:00", utc},
+		// Go's time.Time.String
+		{"2024-03-05 14:30:00 +0000 UTC", utc},
+		{"2024-03-05 15:30:00 +0100 CET", utc},
+		{"2024-03-05 17:30:00 +0300 +03", utc},
+		{"2024-03-05 14:30:00.000000123 +0000 UTC m=+0.000000001", utc.Add(123)},
+		// month first
+		{"Mar 5 2024 14:30:00 +0000", utc},
+		{"Tue Mar 5 14:30:00 2024", utc},
+		{"March 5th 2024 14:30", utc},
+		// localized names
+		{"Di, 05 März 2024 14:30:00 +0000", utc},
+		{"mardi 5 mars 2024 14:30:00 +0000", utc},
+		{"martes, 5 marzo 2024 14:30:00 +0000", utc},
+		// dates without a time
+		{"2024-03-05", time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)},
+		{"5 Mar 2024", time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParsePublishedDate(tt.raw)
+		if err != nil {
+			t.Errorf("ParsePublishedDate(%q): %v", tt.raw, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParsePublishedDate(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParsePublishedDateFails(t *testing.T) {
+	// the caller falls back to the current time for these
+	for _, raw := range []string{"", "   ", "yesterday", "32 Foo 2024", "2024-13-45"} {
+		if got, err := ParsePublishedDate(raw); err == nil {
+			t.Errorf("ParsePublishedDate(%q) = %v, want an error", raw, got)
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/boxy-pug/gator/internal/config"
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Download statuses.
+const (
+	DownloadStatusPending     = "pending"
+	DownloadStatusDownloading = "downloading"
+	DownloadStatusComplete    = "complete"
+	DownloadStatusFailed      = "failed"
+	DownloadStatusDeleted     = "deleted"
+)
+
+// defaultDownloadKeep is how many episodes per feed are kept when neither the feed nor
+// download_keep in .gatorconfig.json says otherwise.
+const defaultDownloadKeep = 3
+
+// DownloadOptions configures DownloadEnclosures.
+type DownloadOptions struct {
+	Fetcher *Fetcher
+	Dir     string
+	Keep    int
+	// Verify recomputes the checksum of files downloaded earlier instead of only checking their size.
+	Verify bool
+}
+
+// DownloadOptionsFromConfig fills in the download_* settings from .gatorconfig.json.
+func DownloadOptionsFromConfig(c *config.Config, fetcher *Fetcher) (DownloadOptions, error) {
+	dir := c.DownloadDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return DownloadOptions{}, fmt.Errorf("could not find a default download_dir: %w", err)
+		}
+		dir = filepath.Join(home, "gator", "downloads")
+	}
+
+	keep := defaultDownloadKeep
+	if c.DownloadKeep > 0 {
+		keep = c.DownloadKeep
+	}
+
+	return DownloadOptions{Fetcher: fetcher, Dir: dir, Keep: keep}, nil
+}
+
+// DownloadStats counts what a DownloadEnclosures run did.
+type DownloadStats struct {
+	Downloaded int
+	Resumed    int
+	Failed     int
+	Pruned     int
+}
+
+// DownloadEnclosures downloads the newest episodes of every feed the user follows and
+// deletes the ones that fell out of their feed's retention window.
+func DownloadEnclosures(ctx context.Context, s *config.State, user database.User, opts DownloadOptions) (DownloadStats, error) {
+	var stats DownloadStats
+	var errs []error
+
+	pruned, err := pruneDownloads(ctx, s, opts.Keep)
+	stats.Pruned = pruned
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	enclosures, err := s.Db.GetEnclosuresToDownload(ctx, database.GetEnclosuresToDownloadParams{
+		DefaultKeep: int32(opts.Keep),
+		UserID:      uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("could not list enclosures to download: %w", err)
+	}
+
+	for _, enclosure := range enclosures {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("stopped downloading: %w", ctx.Err()))
+			break
+		}
+		transfer, err := downloadEnclosure(ctx, s, opts, enclosure)
+		if err != nil {
+			stats.Failed++
+			errs = append(errs, fmt.Errorf("download %s: %w", enclosure.Url, err))
+			continue
+		}
+		if transfer == nil {
+			continue
+		}
+		stats.Downloaded++
+		if transfer.Resumed {
+			stats.Resumed++
+		}
+	}
+
+	return stats, errors.Join(errs...)
+}
+
+// downloadEnclosure makes sure the enclosure is on disk. It returns nil if an earlier
+// download is still good.
+func downloadEnclosure(ctx context.Context, s *config.State, opts DownloadOptions, enclosure database.GetEnclosuresToDownloadRow) (*enclosureTransfer, error) {
+	downloadID := enclosure.DownloadID.UUID
+	filePath := enclosure.Path.String
+	if !enclosure.DownloadID.Valid {
+		var err error
+		filePath, err = enclosurePath(ctx, s, opts.Dir, enclosure)
+		if err != nil {
+			return nil, err
+		}
+		download, err := s.Db.CreateDownload(ctx, database.CreateDownloadParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			EnclosureID: enclosure.EnclosureID,
+			Path:        filePath,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create download: %w", err)
+		}
+		downloadID = download.ID
+	}
+
+	if enclosure.Status.String == DownloadStatusComplete {
+		if downloadIntact(filePath, enclosure.Size, enclosure.Sha256, opts.Verify) {
+			return nil, nil
+		}
+		log.Printf("Download %s is missing or damaged, downloading it again.", filePath)
+		os.Remove(filePath)
+	}
+
+	err := os.MkdirAll(filepath.Dir(filePath), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create download directory: %w", err)
+	}
+	err = s.Db.SetDownloadStatus(ctx, database.SetDownloadStatusParams{ID: downloadID, Status: DownloadStatusDownloading})
+	if err != nil {
+		return nil, fmt.Errorf("could not update download: %w", err)
+	}
+
+	part := filePath + ".part"
+	fail := func(transfer *enclosureTransfer, err error) (*enclosureTransfer, error) {
+		// the status update shouldn't be skipped just because we are shutting down
+		dbErr := s.Db.FailDownload(context.WithoutCancel(ctx), database.FailDownloadParams{
+			ID:              downloadID,
+			Error:           sql.NullString{String: err.Error(), Valid: true},
+			BytesDownloaded: transfer.Written,
+			Size:            sql.NullInt64{Int64: transfer.Size, Valid: transfer.Size >= 0},
+			Etag:            sql.NullString{String: transfer.ETag, Valid: transfer.ETag != ""},
+		})
+		if dbErr != nil {
+			log.Printf("Could not record failed download %s: %v", filePath, dbErr)
+		}
+		return nil, err
+	}
+
+	transfer, err := opts.Fetcher.fetchEnclosure(ctx, enclosure.Url, part, enclosure.Etag.String)
+	if err != nil {
+		return fail(transfer, err)
+	}
+	if transfer.Size >= 0 && transfer.Written != transfer.Size {
+		if transfer.Written > transfer.Size {
+			// more than the server announced, resuming from here would only make it worse
+			os.Remove(part)
+		}
+		return fail(transfer, fmt.Errorf("size mismatch: got %d bytes, expected %d", transfer.Written, transfer.Size))
+	}
+	// the length in the feed is often a guess, so only the server's size is enforced
+	if enclosure.Length.Valid && enclosure.Length.Int64 > 0 && enclosure.Length.Int64 != transfer.Written {
+		log.Printf("Download %s is %d bytes, the feed said %d.", filePath, transfer.Written, enclosure.Length.Int64)
+	}
+
+	sum, err := fileSHA256(part)
+	if err != nil {
+		return fail(transfer, err)
+	}
+	err = os.Rename(part, filePath)
+	if err != nil {
+		return fail(transfer, fmt.Errorf("could not move download into place: %w", err))
+	}
+
+	err = s.Db.CompleteDownload(ctx, database.CompleteDownloadParams{
+		ID:              downloadID,
+		BytesDownloaded: transfer.Written,
+		Sha256:          sql.NullString{String: sum, Valid: true},
+		Etag:            sql.NullString{String: transfer.ETag, Valid: transfer.ETag != ""},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not record download: %w", err)
+	}
+	log.Printf("Downloaded %s (%s).", filePath, formatBytes(transfer.Written))
+	return transfer, nil
+}
+
+// enclosureTransfer describes one fetchEnclosure call. Size is -1 when the server
+// didn't say how large the file is.
+type enclosureTransfer struct {
+	Written int64
+	Size    int64
+	ETag    string
+	Resumed bool
+}
+
+// fetchEnclosure downloads rawURL into part, resuming from whatever part already holds.
+// etag is the validator of the earlier attempt, sent as If-Range so a changed file
+// starts over instead of being spliced onto the old one.
+func (f *Fetcher) fetchEnclosure(ctx context.Context, rawURL, part, etag string) (*enclosureTransfer, error) {
+	transfer := &enclosureTransfer{Size: -1, ETag: etag}
+	if info, err := os.Stat(part); err == nil {
+		transfer.Written = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return transfer, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	// byte ranges only line up with an uncompressed body
+	req.Header.Set("Accept-Encoding", "identity")
+	if transfer.Written > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", transfer.Written))
+		// a weak etag can't be used with If-Range
+		if etag != "" && !strings.HasPrefix(etag, "W/") {
+			req.Header.Set("If-Range", etag)
+		}
+	}
+
+	release, err := f.limits.acquire(ctx, req.URL.Host)
+	if err != nil {
+		return transfer, err
+	}
+	defer release()
+
+	// the download client has no total timeout, so a stalled body cancels the request instead
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := f.downloadClient.Do(req.WithContext(reqCtx))
+	if err != nil {
+		return transfer, fmt.Errorf("failed to fetch enclosure: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != transfer.Written {
+			os.Remove(part)
+			transfer.Written = 0
+			return transfer, fmt.Errorf("server resumed at the wrong offset, starting over next time")
+		}
+		transfer.Size = total
+		transfer.Resumed = true
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		transfer.Written = 0
+		transfer.Size = resp.ContentLength
+		transfer.ETag = resp.Header.Get("ETag")
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the part file may already hold everything
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == transfer.Written {
+			transfer.Size = total
+			return transfer, nil
+		}
+		os.Remove(part)
+		transfer.Written = 0
+		return transfer, fmt.Errorf("server can't resume the download, starting over next time")
+	default:
+		return transfer, statusError(resp)
+	}
+
+	file, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return transfer, fmt.Errorf("could not open download: %w", err)
+	}
+	body := newIdleReader(resp.Body, f.readTimeout, cancel)
+	n, err := io.Copy(file, body)
+	body.stop()
+	closeErr := file.Close()
+	transfer.Written += n
+	if err != nil {
+		if body.stalled.Load() {
+			return transfer, fmt.Errorf("download stalled, no data for %s", f.readTimeout)
+		}
+		return transfer, fmt.Errorf("download interrupted: %w", err)
+	}
+	if closeErr != nil {
+		return transfer, fmt.Errorf("could not write download: %w", closeErr)
+	}
+	return transfer, nil
+}
+
+// idleReader calls cancel when no data arrives for timeout, which aborts the request
+// the body belongs to.
+type idleReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func newIdleReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleReader {
+	ir := &idleReader{r: r, timeout: timeout}
+	ir.timer = time.AfterFunc(timeout, func() {
+		ir.stalled.Store(true)
+		cancel()
+	})
+	return ir
+}
+
+func (ir *idleReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+	if n > 0 && !ir.stalled.Load() {
+		ir.timer.Reset(ir.timeout)
+	}
+	return n, err
+}
+
+func (ir *idleReader) stop() {
+	ir.timer.Stop()
+}
+
+// parseContentRange reads "bytes 100-999/1000" and "bytes */1000". total is -1 when
+// the server doesn't know it.
+func parseContentRange(value string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	byteRange, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = n
+	}
+	if byteRange == "*" {
+		return 0, total, true
+	}
+	first, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// downloadIntact checks a finished download is still on disk. The size check is cheap;
+// the checksum is only recomputed when verify is set.
+func downloadIntact(filePath string, size sql.NullInt64, sum sql.NullString, verify bool) bool {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	if size.Valid && info.Size() != size.Int64 {
+		return false
+	}
+	if verify && sum.Valid {
+		actual, err := fileSHA256(filePath)
+		return err == nil && actual == sum.String
+	}
+	return true
+}
+
+func fileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("could not open download: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("could not checksum download: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// pruneDownloads deletes the files of episodes that fell out of their feed's retention window.
+func pruneDownloads(ctx context.Context, s *config.State, keep int) (int, error) {
+	downloads, err := s.Db.GetDownloadsToPrune(ctx, int32(keep))
+	if err != nil {
+		return 0, fmt.Errorf("could not list downloads to prune: %w", err)
+	}
+
+	pruned := 0
+	var errs []error
+	for _, download := range downloads {
+		if err := removeDownloadFiles(download.Path); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.Db.MarkDownloadDeleted(ctx, download.ID); err != nil {
+			errs = append(errs, fmt.Errorf("could not mark %s deleted: %w", download.Path, err))
+			continue
+		}
+		log.Printf("Deleted %s, it is older than the episodes kept for its feed.", download.Path)
+		pruned++
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// removeDownloadFiles deletes a download and its partial file, whichever exist.
+func removeDownloadFiles(filePath string) error {
+	var errs []error
+	for _, name := range []string{filePath, filePath + ".part"} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("could not delete %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// enclosurePath picks "<dir>/<feed>/<date> <title>.<ext>" for a new download, adding a
+// counter when the name is taken. A name is taken by a file, a partial download or
+// another download row, since failed and pending downloads don't have a file yet.
+func enclosurePath(ctx context.Context, s *config.State, dir string, enclosure database.GetEnclosuresToDownloadRow) (string, error) {
+	feedDir := filepath.Join(dir, sanitizeFileName(enclosure.FeedName))
+	name := sanitizeFileName(enclosure.Title)
+	if enclosure.PublishedAt.Valid {
+		name = enclosure.PublishedAt.Time.Format(time.DateOnly) + " " + name
+	}
+	ext := enclosureExt(enclosure.Url, enclosure.MimeType)
+
+	filePath := filepath.Join(feedDir, name+ext)
+	for i := 2; ; i++ {
+		taken, err := pathTaken(ctx, s, filePath)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return filePath, nil
+		}
+		filePath = filepath.Join(feedDir, fmt.Sprintf("%s (%d)%s", name, i, ext))
+	}
+}
+
+func pathTaken(ctx context.Context, s *config.State, filePath string) (bool, error) {
+	for _, p := range []string{filePath, filePath + ".part"} {
+		_, err := os.Stat(p)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("could not check download path: %w", err)
+		}
+	}
+	taken, err := s.Db.DownloadPathTaken(ctx, filePath)
+	if err != nil {
+		return false, fmt.Errorf("could not check download path: %w", err)
+	}
+	return taken, nil
+}
+
+// podcastExtensions covers the media types mime.ExtensionsByType doesn't know everywhere.
+var podcastExtensions = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/mp3":       ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/x-m4a":     ".m4a",
+	"audio/aac":       ".aac",
+	"audio/ogg":       ".ogg",
+	"audio/opus":      ".opus",
+	"audio/wav":       ".wav",
+	"video/mp4":       ".mp4",
+	"video/x-m4v":     ".m4v",
+	"video/quicktime": ".mov",
+	"video/webm":      ".webm",
+}
+
+// enclosureExt picks the extension from the MIME type when it is a known media type,
+// otherwise from the URL, since many feeds link to something like download.php.
+func enclosureExt(rawURL, mimeType string) string {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	if ext, ok := podcastExtensions[mediaType]; ok {
+		return ext
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		ext := strings.ToLower(path.Ext(u.Path))
+		if len(ext) >= 2 && len(ext) <= 6 && isAlphanumeric(ext[1:]) {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// maxFileNameLen keeps names well below the 255 byte limit of most filesystems.
+const maxFileNameLen = 120
+
+// sanitizeFileName makes a feed or post title safe to use as a file name.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.Join(strings.Fields(name), " "), " .")
+	for len(name) > maxFileNameLen {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// HandlerDownload downloads the newest episodes of the podcasts the user follows into
+// download_dir, keeping download_keep episodes per feed.
+func HandlerDownload(ctx context.Context, s *config.State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	verify := flags.Bool("verify", false, "recompute the checksum of earlier downloads")
+	err := flags.Parse(cmd.Args)
+	if err != nil {
+		return fmt.Errorf("could not parse download flags: %w", err)
+	}
+
+	fetcher, err := NewFetcher(s.Config)
+	if err != nil {
+		return err
+	}
+	opts, err := DownloadOptionsFromConfig(s.Config, fetcher)
+	if err != nil {
+		return err
+	}
+	opts.Verify = *verify
+
+	stats, err := DownloadEnclosures(ctx, s, user, opts)
+	fmt.Printf("%d downloaded (%d resumed), %d failed, %d deleted by retention\n",
+		stats.Downloaded, stats.Resumed, stats.Failed, stats.Pruned)
+	return err
+}
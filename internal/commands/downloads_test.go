@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/config"
+)
+
+func TestFetchEnclosureResumes(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	etag := `"v1"`
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(&config.Config{HostRequestInterval: "1ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := filepath.Join(t.TempDir(), "episode.mp3.part")
+	if err := os.WriteFile(part, content[:300], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	transfer, err := fetcher.fetchEnclosure(context.Background(), server.URL, part, etag)
+	if err != nil {
+		t.Fatalf("fetchEnclosure: %v", err)
+	}
+	if !transfer.Resumed || transfer.Written != int64(len(content)) || transfer.Size != int64(len(content)) {
+		t.Errorf("transfer = %+v", transfer)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=300-" {
+		t.Errorf("Range headers = %q", ranges)
+	}
+	got, _ := os.ReadFile(part)
+	if !bytes.Equal(got, content) {
+		t.Errorf("part file has %d bytes, not the original content", len(got))
+	}
+
+	// a finished part file gets a 416 and counts as complete
+	transfer, err = fetcher.fetchEnclosure(context.Background(), server.URL, part, etag)
+	if err != nil || transfer.Written != int64(len(content)) {
+		t.Errorf("complete part: transfer %+v, err %v", transfer, err)
+	}
+
+	// a changed file fails If-Range, so the download starts over
+	etag = `"v2"`
+	os.WriteFile(part, content[:300], 0o644)
+	transfer, err = fetcher.fetchEnclosure(context.Background(), server.URL, part, `"v1"`)
+	if err != nil {
+		t.Fatalf("fetchEnclosure: %v", err)
+	}
+	if transfer.Resumed || transfer.Written != int64(len(content)) || transfer.ETag != `"v2"` {
+		t.Errorf("changed file: transfer = %+v", transfer)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value        string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 100-999/1000", 100, 1000, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */1000", 0, 1000, true},
+		{"bytes 100-999", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"bytes x-1/2", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.value)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, want %d, %d, %v", tt.value, start, total, ok, tt.start, tt.total, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Errors returned by FetchFeed, so ScrapeFeeds can tell a dead feed from a bad day.
+// ErrHostThrottled means our own per-host limit held the fetch back, not the feed.
+var (
+	ErrFeedNotFound    = errors.New("feed not found")
+	ErrFeedGone        = errors.New("feed is gone")
+	ErrFeedServerError = errors.New("feed server error")
+	ErrFeedRateLimited = errors.New("feed rate limited")
+	ErrNotAFeed        = errors.New("not a feed")
+	ErrFeedTooLarge    = errors.New("feed too large")
+	ErrHostThrottled   = errors.New("host throttled")
+)
+
+// statusError maps a non-2xx response onto one of the feed errors.
+func statusError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrFeedNotFound, resp.Status)
+	case resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: %s", ErrFeedGone, resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", ErrFeedRateLimited, resp.Status)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %s", ErrFeedServerError, resp.Status)
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// maxBytesReader fails with ErrFeedTooLarge once more than limit bytes have been read.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func newMaxBytesReader(r io.Reader, limit int64) *maxBytesReader {
+	return &maxBytesReader{r: r, remaining: limit, limit: limit}
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrFeedTooLarge, m.limit)
+	}
+	// read one byte past the limit to tell a full body from an oversized one
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return n - int(-m.remaining), fmt.Errorf("%w: more than %d bytes", ErrFeedTooLarge, m.limit)
+	}
+	return n, err
+}
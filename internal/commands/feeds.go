@@ -1,14 +1,19 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"log"
-	"net/http"
+	"mime"
 	"strconv"
 	"strings"
 	"time"
@@ -18,13 +23,32 @@ import (
 	"github.com/google/uuid"
 )
 
+// Feed statuses. Only active feeds are fetched by agg.
+const (
+	FeedStatusActive   = "active"
+	FeedStatusGone     = "gone"
+	FeedStatusNotFound = "not_found"
+	FeedStatusDisabled = "disabled"
+)
+
+// maxNotFound is how many 404s in a row it takes before a feed is considered dead.
+const maxNotFound = 3
+
 type RSSFeed struct {
 	Channel struct {
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
+		// Refresh hints, see FeedHints
+		TTL             string   `xml:"ttl"`
+		UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		SkipHours       []string `xml:"skipHours>hour"`
+		SkipDays        []string `xml:"skipDays>day"`
 	} `xml:"channel"`
+	// Warnings lists what had to be repaired or dropped to parse the feed.
+	Warnings []string `xml:"-"`
 }
 
 type RSSItem struct {
@@ -32,52 +56,195 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	Author      string `xml:"author"`
+	GUID        string `xml:"guid"`
+	// Content is the full article when the feed has one besides the Description excerpt.
+	Content    string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Creator    string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories []string `xml:"category"`
+	// Podcast episodes
+	Enclosures []RSSEnclosure `xml:"enclosure"`
+	PodcastInfo
+}
+
+// PostGUID is the identity of the item within its feed. Feeds without guids fall back to the link.
+func (item RSSItem) PostGUID() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	return strings.TrimSpace(item.Link)
 }
 
+// PostAuthor is the RSS author, which is meant to be an email address, or else dc:creator.
+func (item RSSItem) PostAuthor() string {
+	if author := strings.TrimSpace(item.Author); author != "" {
+		return author
+	}
+	return strings.TrimSpace(item.Creator)
+}
+
+// PostCategories trims the categories and drops empty and duplicate ones. It never
+// returns nil, the column doesn't take NULL.
+func (item RSSItem) PostCategories() []string {
+	categories := []string{}
+	seen := map[string]bool{}
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+// ContentHash fingerprints the parts of a post that can be corrected after publishing.
+func ContentHash(title, description, content string) string {
+	sum := sha256.Sum256([]byte(title + "\x00" + description + "\x00" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+// PostURL is the item link, or the guid when that is a permalink and the link is missing.
+func (item RSSItem) PostURL() string {
+	link := strings.TrimSpace(item.Link)
+	guid := strings.TrimSpace(item.GUID)
+	if link == "" && (strings.HasPrefix(guid, "http://") || strings.HasPrefix(guid, "https://")) {
+		return guid
+	}
+	return link
+}
+
+// ResolveLinks makes relative channel, item and enclosure links absolute against the URL
+// the feed was fetched from. Items without a guid are identified by their link, so the
+// link as written in the feed is kept as their guid.
+func ResolveLinks(feed *RSSFeed, feedURL string) {
+	// an empty link would resolve to the feed URL itself
+	resolve := func(link string) string {
+		if strings.TrimSpace(link) == "" {
+			return ""
+		}
+		return resolveURL(feedURL, link)
+	}
+	feed.Channel.Link = resolve(feed.Channel.Link)
+	for i := range feed.Channel.Item {
+		item := &feed.Channel.Item[i]
+		if strings.TrimSpace(item.GUID) == "" {
+			item.GUID = strings.TrimSpace(item.Link)
+		}
+		item.Link = resolve(item.Link)
+		for j := range item.Enclosures {
+			item.Enclosures[j].URL = resolve(item.Enclosures[j].URL)
+		}
+	}
+}
+
+// UnescapeHTML decodes the entities RSS feeds commonly leave in titles, like &amp;#8217;.
+// Item descriptions are HTML and are left for SanitizeHTML, unescaping them again
+// would turn escaped text like &lt;script&gt; into markup.
 func UnescapeHTML(feed *RSSFeed) {
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
 	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
 	for i := range feed.Channel.Item {
 		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
-		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
 	}
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// ParseFeed works out the feed format from the content type or the start of the document
+// and decodes it as RSS 2.0, RSS 1.0 (RDF), Atom 1.0 or JSON Feed, always returning the RSS item model.
+// The document is decoded as a stream, so r can be a size-limited response body, and
+// converted to UTF-8 on the way.
+func ParseFeed(r io.Reader, contentType string) (*RSSFeed, error) {
+	br := bufio.NewReader(r)
+	utf8, converted := utf8Reader(br, contentType)
+	if converted {
+		br = bufio.NewReader(utf8)
 	}
 
-	req.Header.Set("User-Agent", "gator")
-
-	client := &http.Client{}
+	if isJSONFeed(br, contentType) {
+		return parseJSONFeed(br, contentType)
+	}
 
-	resp, err := client.Do(req)
+	// decode strictly first and keep a copy of what was read, so a document that isn't
+	// well formed can be parsed again leniently from the start
+	stripper := &controlCharStripper{r: br}
+	var consumed bytes.Buffer
+	decoder := xml.NewDecoder(io.TeeReader(stripper, &consumed))
+	decoder.CharsetReader = xmlCharsetReader(converted)
+	feed, err := parseXMLFeed(decoder, contentType)
+
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		decoder = newLenientDecoder(io.MultiReader(&consumed, stripper))
+		decoder.CharsetReader = xmlCharsetReader(converted)
+		feed, err = parseXMLFeed(decoder, contentType)
+		// a salvaged document already says what went wrong
+		if err == nil && len(feed.Warnings) == 0 {
+			feed.Warnings = append(feed.Warnings, fmt.Sprintf("feed is not well-formed xml, parsed it leniently: %v", syntaxErr))
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+		return nil, err
 	}
+	if warning := stripper.warning(); warning != "" {
+		feed.Warnings = append(feed.Warnings, warning)
+	}
+	return feed, nil
+}
 
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+// parseXMLFeed decodes an RSS, Atom or RDF document depending on its root element.
+func parseXMLFeed(decoder *xml.Decoder, contentType string) (*RSSFeed, error) {
+	start, err := xmlRoot(decoder)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read resp body: %w", err)
+		if errors.Is(err, ErrFeedTooLarge) {
+			return nil, err
+		}
+		// an html error page served with a 200 usually isn't well formed xml
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/html" {
+			return nil, fmt.Errorf("%w: got %s", ErrNotAFeed, mediaType)
+		}
+		return nil, fmt.Errorf("error unmarshaling xml: %w", err)
 	}
 
+	switch start.Name.Local {
+	case "rss":
+		return parseRSS(decoder, start)
+	case "feed":
+		return parseAtom(decoder, start)
+	case "RDF":
+		return parseRDF(decoder, start)
+	}
+	return nil, fmt.Errorf("%w: unexpected root element <%s>", ErrNotAFeed, start.Name.Local)
+}
+
+func parseRSS(decoder *xml.Decoder, start xml.StartElement) (*RSSFeed, error) {
 	var feed RSSFeed
-	err = xml.Unmarshal(body, &feed)
+	err := decoder.DecodeElement(&feed, &start)
+	warning, err := salvageError(decoder, err, len(feed.Channel.Item))
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshaling xml: %w", err)
 	}
-
+	if warning != "" {
+		feed.Warnings = append(feed.Warnings, warning)
+	}
 	UnescapeHTML(&feed)
-
 	return &feed, nil
+}
 
+// xmlRoot reads up to and including the first element of the document.
+func xmlRoot(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
 }
 
-func HandlerAddFeed(s *config.State, cmd Command, user database.User) error {
+func HandlerAddFeed(ctx context.Context, s *config.State, cmd Command, user database.User) error {
 	if len(cmd.Args) < 2 {
 		return fmt.Errorf("expecting two args, name and url")
 	}
@@ -85,63 +252,81 @@ func HandlerAddFeed(s *config.State, cmd Command, user database.User) error {
 	feedName := cmd.Args[0]
 	feedUrl := cmd.Args[1]
 
+	// the url may be one a feed we already have was redirected away from
+	existingID, err := s.Db.GetFeedByUrl(ctx, sql.NullString{String: feedUrl, Valid: true})
+	if err == nil {
+		existing, err := s.Db.GetFeedById(ctx, existingID)
+		if err != nil {
+			return fmt.Errorf("error fetching feed: %w", err)
+		}
+		fmt.Printf("Feed %s already exists at %s, following it instead.\n", existing.Name, existing.Url.String)
+		return HandlerFollow(ctx, s, Command{Name: "follow", Args: []string{existing.Url.String}}, user)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error looking up feed: %w", err)
+	}
+
 	feedID := uuid.New()
-	feed, err := s.Db.CreateFeed(context.Background(), database.CreateFeedParams{
+	feed, err := s.Db.CreateFeed(ctx, database.CreateFeedParams{
 		ID:     feedID,
 		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
 		Name:   feedName,
 		Url:    sql.NullString{String: feedUrl, Valid: true},
 	})
 	if err != nil {
-		fmt.Errorf("error creating feed: %w", err)
+		return fmt.Errorf("error creating feed: %w", err)
 	}
 
 	// Call HandlerFollow to follow the newly added feed
 	followCmd := Command{Name: "follow", Args: []string{feedUrl}}
-	err = HandlerFollow(s, followCmd, user)
+	err = HandlerFollow(ctx, s, followCmd, user)
 	if err != nil {
 		return fmt.Errorf("error following feed: %w", err)
 	}
 
 	// Print the details of the new feed
-	fmt.Printf("Feed added successfully:\nID: %s\nName: %s\nURL: %s\n", feed.ID, feed.Name, feed.Url)
-	log.Printf("Feed added: ID=%s, Name=%s, URL=%s, UserID=%s\n", feed.ID, feed.Name, feed.Url, user.ID)
+	fmt.Printf("Feed added successfully:\nID: %s\nName: %s\nURL: %s\n", feed.ID, feed.Name, feed.Url.String)
+	log.Printf("Feed added: ID=%s, Name=%s, URL=%s, UserID=%s\n", feed.ID, feed.Name, feed.Url.String, user.ID)
 
 	return nil
 
 }
-func HandlerFeeds(s *config.State, cmd Command) error {
-	feeds, err := s.Db.GetFeeds(context.Background())
+func HandlerFeeds(ctx context.Context, s *config.State, cmd Command) error {
+	feeds, err := s.Db.GetFeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("error fetching feeds: %w", err)
 	}
 
 	for _, feed := range feeds {
-		userName, err := s.Db.GetUserFromId(context.Background(), feed.UserID.UUID)
+		userName, err := s.Db.GetUserFromId(ctx, feed.UserID.UUID)
 		if err != nil {
 			return fmt.Errorf("error fetching username from uuid: %w", err)
 		}
-		fmt.Printf("%s\n", feed.Name)
+		if feed.Status != FeedStatusActive {
+			fmt.Printf("%s (%s)\n", feed.Name, feed.Status)
+		} else {
+			fmt.Printf("%s\n", feed.Name)
+		}
 		fmt.Printf("%v\n", feed.Url.String)
 		fmt.Printf("%s\n", userName)
 	}
 	return nil
 }
 
-func HandlerFollow(s *config.State, cmd Command, user database.User) error {
+func HandlerFollow(ctx context.Context, s *config.State, cmd Command, user database.User) error {
 	if len(cmd.Args) < 1 {
 		return fmt.Errorf("expecting url argument")
 	}
 
 	feedUrl := cmd.Args[0]
 
-	feedId, err := s.Db.GetFeedByUrl(context.Background(), sql.NullString{String: feedUrl, Valid: true})
+	feedId, err := s.Db.GetFeedByUrl(ctx, sql.NullString{String: feedUrl, Valid: true})
 	if err != nil {
 		return fmt.Errorf("error getting feed by url: %w", err)
 	}
 
 	feedFollowId := uuid.New()
-	feedFollow, err := s.Db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	feedFollow, err := s.Db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		ID:        feedFollowId,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -149,7 +334,7 @@ func HandlerFollow(s *config.State, cmd Command, user database.User) error {
 		FeedID:    uuid.NullUUID{UUID: feedId, Valid: true},
 	})
 	if err != nil {
-		fmt.Errorf("error creating feed follow: %w", err)
+		return fmt.Errorf("error creating feed follow: %w", err)
 	}
 
 	fmt.Printf("Successfully followed feed:\nUser: %s\nFeed: %s\n", feedFollow.UserName, feedFollow.FeedName)
@@ -162,11 +347,11 @@ func HandlerFollow(s *config.State, cmd Command, user database.User) error {
 //It should print the name of the feed and the current user once the record is created
 //(which the query we just made should support). You'll need a query to look up feeds by URL.
 
-func HandlerFollowing(s *config.State, cmd Command, user database.User) error {
+func HandlerFollowing(ctx context.Context, s *config.State, cmd Command, user database.User) error {
 
-	feeds, err := s.Db.GetFeedFollowsForUser(context.Background(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	feeds, err := s.Db.GetFeedFollowsForUser(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
 	if err != nil {
-		fmt.Errorf("error retreiving user feeds: %w", err)
+		return fmt.Errorf("error retreiving user feeds: %w", err)
 	}
 
 	for _, feed := range feeds {
@@ -177,13 +362,13 @@ func HandlerFollowing(s *config.State, cmd Command, user database.User) error {
 
 }
 
-func HandlerDeleteFeed(s *config.State, cmd Command, user database.User) error {
+func HandlerDeleteFeed(ctx context.Context, s *config.State, cmd Command, user database.User) error {
 	if len(cmd.Args) < 1 {
-		fmt.Errorf("please provide url for unfollowing")
+		return fmt.Errorf("please provide url for unfollowing")
 	}
 	url := cmd.Args[0]
 
-	err := s.Db.DeleteFollowFeed(context.Background(), database.DeleteFollowFeedParams{
+	err := s.Db.DeleteFollowFeed(ctx, database.DeleteFollowFeedParams{
 		Url:    sql.NullString{String: url, Valid: true},
 		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
 	})
@@ -195,68 +380,7 @@ func HandlerDeleteFeed(s *config.State, cmd Command, user database.User) error {
 	return nil
 }
 
-/*
-Write an aggregation function, I called mine scrapeFeeds. It should:
-
-    Get the next feed to fetch from the DB.
-    Mark it as fetched.
-    Fetch the feed using the URL (we already wrote this function)
-    Iterate over the items in the feed and print their titles to the console.
-*/
-
-func ScrapeFeeds(s *config.State) error {
-	nextFeed, err := s.Db.GetNextFeedToFetch(context.Background())
-	if err != nil {
-		return fmt.Errorf("could not fetch next feed; %w", err)
-	}
-	// Mark the feed as fetched
-	err = s.Db.MarkFeedFetched(context.Background(), nextFeed.ID)
-	if err != nil {
-		return fmt.Errorf("could not mark feed as fetched: %w", err)
-	}
-	//nextFeed.LastFetchedAt = sql.NullTime{Time: time.Now(), Valid: true}
-
-	fetchedFeed, err := FetchFeed(context.Background(), nextFeed.Url.String)
-	if err != nil {
-		return fmt.Errorf("could not fetch feed content:%w", err)
-	}
-
-	for _, item := range fetchedFeed.Channel.Item {
-		publishedAt, err := time.Parse(time.RFC1123Z, item.PubDate)
-		if err != nil {
-			log.Printf("error fetching published date for %s, using current time instead", item.Title)
-			publishedAt = time.Now()
-		}
-		postID := uuid.New()
-		post := database.CreatePostParams{
-			ID:          postID,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: sql.NullString{String: item.Description, Valid: item.Description != ""},
-			PublishedAt: sql.NullTime{Time: publishedAt, Valid: true},
-			FeedID:      uuid.NullUUID{UUID: nextFeed.ID, Valid: true},
-		}
-
-		_, err = s.Db.CreatePost(context.Background(), post)
-		if err != nil {
-			if strings.Contains(err.Error(), "unique constraint") {
-				// This indicates a unique constraint violation, which we can safely ignore
-				log.Printf("Post with URL %s already exists. Skipping.", item.Link)
-				continue
-			}
-			log.Printf("Error saving post %s: %v", item.Title, err)
-		}
-		//_, err = s.Db.CreatePost(context.Background(), post)
-		//if err != nil {
-		//	log.Printf("error saving post %v", item.Title)
-		//}
-	}
-	return nil
-}
-
-func HandlerBrowse(s *config.State, cmd Command, user database.User) error {
+func HandlerBrowse(ctx context.Context, s *config.State, cmd Command, user database.User) error {
 	limit := 2
 	if len(cmd.Args) > 0 {
 		l, err := strconv.Atoi(cmd.Args[0])
@@ -265,7 +389,7 @@ func HandlerBrowse(s *config.State, cmd Command, user database.User) error {
 		}
 
 	}
-	posts, err := s.Db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+	posts, err := s.Db.GetPostsForUser(ctx, database.GetPostsForUserParams{
 		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
 		Limit:  int32(limit),
 	})
@@ -278,8 +402,33 @@ func HandlerBrowse(s *config.State, cmd Command, user database.User) error {
 		return nil
 	}
 
+	postIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	enclosures, err := s.Db.GetEnclosuresForPosts(ctx, postIDs)
+	if err != nil {
+		return fmt.Errorf("error retrieving enclosures: %w", err)
+	}
+	enclosuresByPost := make(map[uuid.UUID][]database.Enclosure)
+	for _, enclosure := range enclosures {
+		enclosuresByPost[enclosure.PostID] = append(enclosuresByPost[enclosure.PostID], enclosure)
+	}
+
 	for _, post := range posts {
-		fmt.Printf("Post Title: %s\n, URL: %s\n, Published At: %v\n\n", post.Title, post.Url, post.PublishedAt)
+		title := stripControlChars(post.Title)
+		if post.Revisions > 0 {
+			title = "[updated] " + title
+		}
+		fmt.Printf("Post Title: %s\n, URL: %s\n, Published At: %v\n", title, stripControlChars(post.Url), post.PublishedAt)
+		if post.Author.Valid {
+			fmt.Printf(", Author: %s\n", stripControlChars(post.Author.String))
+		}
+		if summary := summarize(htmlToText(post.Description.String), summaryLength); summary != "" {
+			fmt.Printf(", Summary: %s\n", summary)
+		}
+		printEnclosures(enclosuresByPost[post.ID], ", ")
+		fmt.Println()
 	}
 
 	return nil
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFeedRSS(t *testing.T) {
+	const doc = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Site</title>
+<link>http://site/</link>
+<ttl>60</ttl>
+<item>
+<title>First</title>
+<link>http://site/1</link>
+<description>One</description>
+<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate>
+<guid>post-1</guid>
+</item>
+</channel>
+</rss>`
+
+	feed, err := ParseFeed(strings.NewReader(doc), "application/rss+xml")
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	if len(feed.Warnings) != 0 {
+		t.Errorf("Warnings = %q, want none", feed.Warnings)
+	}
+	if feed.Channel.Link != "http://site/" || feed.Channel.TTL != "60" {
+		t.Errorf("channel link %q, ttl %q", feed.Channel.Link, feed.Channel.TTL)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+	item := feed.Channel.Item[0]
+	if item.Link != "http://site/1" || item.PubDate != "Mon, 02 Jan 2006 15:04:05 +0000" || item.GUID != "post-1" || item.Description != "One" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestParseFeedRDF(t *testing.T) {
+	const doc = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="http://site/">
+<title>Site</title>
+<link>http://site/</link>
+</channel>
+<item rdf:about="http://site/1">
+<title>First</title>
+<link>http://site/1</link>
+<dc:date>2006-01-02T15:04:05Z</dc:date>
+<dc:creator>Ann</dc:creator>
+</item>
+</rdf:RDF>`
+
+	feed, err := ParseFeed(strings.NewReader(doc), "application/rdf+xml")
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	if feed.Channel.Link != "http://site/" {
+		t.Errorf("channel link %q", feed.Channel.Link)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+	item := feed.Channel.Item[0]
+	if item.Link != "http://site/1" || item.PubDate != "Mon, 02 Jan 2006 15:04:05 +0000" || item.GUID != "http://site/1" || item.Author != "Ann" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestParseFeedLenient(t *testing.T) {
+	const doc = `<rss><channel><title>Tom & Jerry&nbsp;</title><link>http://site/</link>
+<item><title>First</title><link>http://site/1</link><guid>post-1</guid></item>
+<item><title>Second</title><link>http://site/2</link><guid>post-2</guid></item>
+</channel></rss>`
+
+	feed, err := ParseFeed(strings.NewReader(doc), "application/rss+xml")
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	if len(feed.Warnings) == 0 {
+		t.Error("expected a warning for the bare ampersand")
+	}
+	if feed.Channel.Link != "http://site/" || len(feed.Channel.Item) != 2 {
+		t.Fatalf("channel link %q, %d items", feed.Channel.Link, len(feed.Channel.Item))
+	}
+	if item := feed.Channel.Item[1]; item.Link != "http://site/2" || item.GUID != "post-2" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestParseFeedAtomEscapedText(t *testing.T) {
+	const doc = `<feed xmlns="http://www.w3.org/2005/Atom">
+<title type="html">Q&amp;amp;A</title>
+<entry>
+<id>post-1</id>
+<title>Scripts</title>
+<content type="html">&lt;p&gt;Never write &amp;lt;script&amp;gt; inline.&lt;/p&gt;&lt;p&gt;More text.&lt;/p&gt;</content>
+</entry>
+</feed>`
+
+	feed, err := ParseFeed(strings.NewReader(doc), "application/atom+xml")
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	SanitizeHTML(feed, "http://site/feed")
+	if feed.Channel.Title != "Q&A" {
+		t.Errorf("title = %q", feed.Channel.Title)
+	}
+	want := "<p>Never write &lt;script&gt; inline.</p><p>More text.</p>"
+	if item := feed.Channel.Item[0]; item.Description != want || item.Content != want {
+		t.Errorf("description %q, content %q, want %q", item.Description, item.Content, want)
+	}
+}
+
+func TestResolveAtomLinks(t *testing.T) {
+	const doc = `<feed xmlns="http://www.w3.org/2005/Atom">
+<link href="/"/>
+<entry>
+<id>post-1</id>
+<link href="/p/1"/>
+</entry>
+<entry xml:base="/blog/">
+<id>post-2</id>
+<link href="p/2"/>
+<link rel="enclosure" href="p/2.mp3" type="audio/mpeg"/>
+</entry>
+</feed>`
+
+	feed, err := ParseFeed(strings.NewReader(doc), "application/atom+xml")
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	ResolveLinks(feed, "https://site/feed.xml")
+	if feed.Channel.Link != "https://site/" {
+		t.Errorf("channel link %q", feed.Channel.Link)
+	}
+	if link := feed.Channel.Item[0].Link; link != "https://site/p/1" {
+		t.Errorf("first link %q", link)
+	}
+	item := feed.Channel.Item[1]
+	if item.Link != "https://site/blog/p/2" || item.Enclosures[0].URL != "https://site/blog/p/2.mp3" {
+		t.Errorf("second link %q, enclosure %q", item.Link, item.Enclosures[0].URL)
+	}
+}
@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/config"
+)
+
+// Defaults for the fetch_* settings in .gatorconfig.json.
+const (
+	defaultConnectTimeout   = 10 * time.Second
+	defaultReadTimeout      = 30 * time.Second
+	defaultTotalTimeout     = time.Minute
+	defaultMaxResponseBytes = 10 << 20
+	defaultMaxRedirects     = 5
+	defaultUserAgent        = "gator"
+)
+
+// Fetcher downloads feeds. It holds one http.Client for all fetches so connections are
+// reused, and the per-host limits that keep agg polite.
+type Fetcher struct {
+	client *http.Client
+	// downloadClient has no total timeout, media files can take a while. Downloads are
+	// cancelled instead when the body stalls for readTimeout.
+	downloadClient   *http.Client
+	readTimeout      time.Duration
+	userAgent        string
+	maxResponseBytes int64
+	limits           *hostLimiter
+}
+
+// FetchResult is what FetchFeed got back. When the server answers 304 Not Modified,
+// NotModified is set and Feed is nil.
+type FetchResult struct {
+	Feed         *RSSFeed
+	NotModified  bool
+	ETag         string
+	LastModified string
+	// CacheLifetime is how long the server says the response stays fresh.
+	CacheLifetime time.Duration
+	// PermanentURL is where the feed lives now if we only got there through 301/308 redirects.
+	PermanentURL string
+	// StatusCode and the byte counts below go into the fetch log. BytesTransferred is the
+	// body as sent over the wire, BytesDecoded after undoing ContentEncoding.
+	StatusCode       int
+	ContentEncoding  string
+	BytesTransferred int64
+	BytesDecoded     int64
+}
+
+// NewFetcher builds a Fetcher from the fetch_*, user_agent and host_* settings in .gatorconfig.json.
+func NewFetcher(c *config.Config) (*Fetcher, error) {
+	connectTimeout, err := parseConfigTimeout("fetch_connect_timeout", c.FetchConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	readTimeout, err := parseConfigTimeout("fetch_read_timeout", c.FetchReadTimeout, defaultReadTimeout)
+	if err != nil {
+		return nil, err
+	}
+	totalTimeout, err := parseConfigTimeout("fetch_total_timeout", c.FetchTotalTimeout, defaultTotalTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if c.FetchProxy != "" {
+		proxyURL, err := url.Parse(c.FetchProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch_proxy %q: %w", c.FetchProxy, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	maxRedirects := defaultMaxRedirects
+	if c.FetchMaxRedirects > 0 {
+		maxRedirects = c.FetchMaxRedirects
+	}
+
+	maxResponseBytes := int64(defaultMaxResponseBytes)
+	if c.FetchMaxResponseBytes > 0 {
+		maxResponseBytes = c.FetchMaxResponseBytes
+	}
+
+	userAgent := defaultUserAgent
+	if c.UserAgent != "" {
+		userAgent = c.UserAgent
+	}
+
+	limits, err := hostLimiterFromConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   limits.concurrency,
+		ForceAttemptHTTP2:     true,
+	}
+
+	checkRedirect := func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	client := &http.Client{
+		Transport:     transport,
+		Timeout:       totalTimeout,
+		CheckRedirect: checkRedirect,
+	}
+	downloadClient := &http.Client{
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
+	}
+
+	return &Fetcher{
+		client:           client,
+		downloadClient:   downloadClient,
+		readTimeout:      readTimeout,
+		userAgent:        userAgent,
+		maxResponseBytes: maxResponseBytes,
+		limits:           limits,
+	}, nil
+}
+
+// parseConfigDuration parses a duration setting, using def when it isn't set.
+func parseConfigDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return d, nil
+}
+
+// parseConfigTimeout is parseConfigDuration for timeouts, where 0 would mean no timeout at all.
+func parseConfigTimeout(name, value string, def time.Duration) (time.Duration, error) {
+	d, err := parseConfigDuration(name, value, def)
+	if err == nil && d == 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be longer than 0", name, value)
+	}
+	return d, err
+}
+
+// FetchFeed downloads and parses a feed. etag and lastModified come from the previous
+// fetch and are sent as If-None-Match/If-Modified-Since when set. Once the server has
+// answered, the result is returned even with an error, so failed fetches still have
+// their status and byte counts for the fetch log.
+func (f *Fetcher) FetchFeed(ctx context.Context, feedURL, etag, lastModified string) (*FetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	release, err := f.limits.acquire(ctx, req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	result := &FetchResult{
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		CacheLifetime: cacheLifetime(resp.Header, time.Now()),
+		PermanentURL:  permanentRedirect(resp),
+		StatusCode:    resp.StatusCode,
+	}
+	transferred := &countingReader{r: resp.Body}
+	defer func() {
+		result.BytesTransferred = transferred.n
+	}()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if wait, ok := retryAfter(resp.Header, time.Now()); ok {
+			f.limits.backOff(req.URL.Host, time.Now().Add(wait))
+			return result, &RetryAfterError{Err: statusError(resp), RetryAfter: wait}
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		// a 304 doesn't have to repeat the validators
+		if result.ETag == "" {
+			result.ETag = etag
+		}
+		if result.LastModified == "" {
+			result.LastModified = lastModified
+		}
+		return result, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// read the error page so it shows up in the fetch log and the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(transferred, f.maxResponseBytes))
+		return result, statusError(resp)
+	}
+
+	decoded, encoding, err := decodeBody(resp, transferred)
+	if err != nil {
+		return result, err
+	}
+	defer decoded.Close()
+	result.ContentEncoding = encoding
+	// the limit applies after decompression so a small compressed body can't expand without bound
+	body := &countingReader{r: newMaxBytesReader(decoded, f.maxResponseBytes)}
+	defer func() {
+		result.BytesDecoded = body.n
+	}()
+	feed, err := ParseFeed(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return result, err
+	}
+	// the parser stops at the end of the root element; read the rest so the counts cover
+	// the whole response and the connection can be reused
+	io.Copy(io.Discard, body)
+
+	ResolveLinks(feed, resp.Request.URL.String())
+	SanitizeHTML(feed, resp.Request.URL.String())
+	result.Feed = feed
+
+	return result, nil
+}
+
+// permanentRedirect follows the redirect chain from the original request and returns the
+// last URL reached through permanent redirects only, or "" if the first hop wasn't permanent.
+func permanentRedirect(resp *http.Response) string {
+	// the redirect responses, from the last hop back to the first
+	var hops []*http.Response
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		hops = append(hops, req.Response)
+	}
+
+	target := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		code := hops[i].StatusCode
+		if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			break
+		}
+		// the request hop i led to is the one the next hop answered, or the final one
+		if i == 0 {
+			target = resp.Request.URL.String()
+		} else {
+			target = hops[i-1].Request.URL.String()
+		}
+	}
+	return target
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/config"
+)
+
+func TestNewFetcherTimeouts(t *testing.T) {
+	for _, c := range []config.Config{
+		{FetchConnectTimeout: "0s"},
+		{FetchReadTimeout: "0"},
+		{FetchTotalTimeout: "-1s"},
+		{FetchTotalTimeout: "soon"},
+	} {
+		if _, err := NewFetcher(&c); err == nil {
+			t.Errorf("NewFetcher(%+v) accepted the timeout", c)
+		}
+	}
+
+	fetcher, err := NewFetcher(&config.Config{FetchTotalTimeout: "5s", HostRequestInterval: "0s"})
+	if err != nil {
+		t.Fatalf("NewFetcher: %v", err)
+	}
+	if fetcher.client.Timeout != 5*time.Second {
+		t.Errorf("total timeout = %v, want 5s", fetcher.client.Timeout)
+	}
+	if fetcher.limits.interval != 0 {
+		t.Errorf("host interval = %v, want 0", fetcher.limits.interval)
+	}
+}
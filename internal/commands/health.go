@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/config"
+	"github.com/boxy-pug/gator/internal/database"
+)
+
+// Failed feeds are retried after baseBackoff, doubling with every further failure up to maxBackoff.
+const (
+	baseBackoff = 5 * time.Minute
+	maxBackoff  = 24 * time.Hour
+)
+
+// failureBackoff returns how long to wait after the given number of consecutive failures.
+func failureBackoff(failures int32) time.Duration {
+	backoff := baseBackoff
+	for i := int32(1); i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// recordFeedHealth stores the outcome of a scrape on the feed row. Failures push the
+// next fetch out and disable the feed after maxFailures in a row.
+func recordFeedHealth(ctx context.Context, s *config.State, feed database.Feed, scrapeErr error, maxFailures int) {
+	if scrapeErr == nil {
+		err := s.Db.RecordFeedSuccess(ctx, feed.ID)
+		if err != nil {
+			log.Printf("Error recording success for feed %s: %v", feed.Name, err)
+		}
+		return
+	}
+
+	// held back by our own per-host limit: not the feed's fault, just try again later
+	if errors.Is(scrapeErr, ErrHostThrottled) {
+		wait := retryAfterFromError(scrapeErr)
+		err := s.Db.SetFeedNextFetch(ctx, database.SetFeedNextFetchParams{
+			ID:           feed.ID,
+			DelaySeconds: wait.Seconds(),
+		})
+		if err != nil {
+			log.Printf("Error rescheduling feed %s: %v", feed.Name, err)
+			return
+		}
+		log.Printf("Feed %s is waiting for its host, fetching it in %s.", feed.Name, wait.Round(time.Second))
+		return
+	}
+
+	// a Retry-After from the server wins over our own backoff when it's longer
+	backoff := max(failureBackoff(feed.ConsecutiveFailures+1), retryAfterFromError(scrapeErr))
+	row, err := s.Db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ID:             feed.ID,
+		LastError:      sql.NullString{String: scrapeErr.Error(), Valid: true},
+		BackoffSeconds: backoff.Seconds(),
+		MaxFailures:    int32(maxFailures),
+	})
+	if err != nil {
+		log.Printf("Error recording failure for feed %s: %v", feed.Name, err)
+		return
+	}
+	if row.Status == FeedStatusDisabled {
+		log.Printf("Feed %s failed %d times in a row, disabled it. Use 'gator feed enable %s' to retry it.", feed.Name, row.ConsecutiveFailures, feed.Url.String)
+		return
+	}
+	log.Printf("Feed %s failed %d time(s) in a row, retrying in %s.", feed.Name, row.ConsecutiveFailures, backoff)
+}
+
+// HandlerFeedHealth lists all feeds with their fetch state, failing feeds first.
+func HandlerFeedHealth(ctx context.Context, s *config.State, cmd Command) error {
+	feeds, err := s.Db.GetFeedHealth(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching feed health: %w", err)
+	}
+
+	for _, feed := range feeds {
+		fmt.Printf("%s (%s)\n", feed.Name, feed.Status)
+		fmt.Printf("  URL: %s\n", feed.Url.String)
+		fmt.Printf("  Last fetched: %s\n", formatNullTime(feed.LastFetchedAt))
+		fmt.Printf("  Last success: %s\n", formatNullTime(feed.LastSuccessAt))
+		if feed.ConsecutiveFailures > 0 {
+			fmt.Printf("  Consecutive failures: %d\n", feed.ConsecutiveFailures)
+		}
+		if feed.NextFetchAt.Valid {
+			fmt.Printf("  Next fetch: %s\n", formatNullTime(feed.NextFetchAt))
+		}
+		if feed.LastError.Valid {
+			fmt.Printf("  Last error (%s): %s\n", formatNullTime(feed.LastErrorAt), feed.LastError.String)
+		}
+		if feed.ParseWarning.Valid {
+			fmt.Printf("  Parse warning: %s\n", feed.ParseWarning.String)
+		}
+	}
+	return nil
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "never"
+	}
+	return t.Time.Format(time.DateTime)
+}
+
+// HandlerFeed manages a single feed: "feed enable <url>" puts a disabled feed back into
+// rotation, "feed disable <url>" takes it out and "feed keep <url> <n|default>" sets how
+// many episodes download keeps for it.
+func HandlerFeed(ctx context.Context, s *config.State, cmd Command) error {
+	if len(cmd.Args) < 2 {
+		return fmt.Errorf("usage: feed <enable|disable> <url> | feed keep <url> <n|default>")
+	}
+	action := cmd.Args[0]
+	url := sql.NullString{String: cmd.Args[1], Valid: true}
+
+	var name string
+	var err error
+	switch action {
+	case "enable":
+		name, err = s.Db.EnableFeed(ctx, url)
+	case "disable":
+		name, err = s.Db.DisableFeed(ctx, url)
+	case "keep":
+		if len(cmd.Args) < 3 {
+			return fmt.Errorf("usage: feed keep <url> <n|default>")
+		}
+		var keep sql.NullInt32
+		if cmd.Args[2] != "default" {
+			n, err := strconv.Atoi(cmd.Args[2])
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid episode count %q", cmd.Args[2])
+			}
+			keep = sql.NullInt32{Int32: int32(n), Valid: true}
+		}
+		name, err = s.Db.SetFeedDownloadKeep(ctx, database.SetFeedDownloadKeepParams{Url: url, DownloadKeep: keep})
+	default:
+		return fmt.Errorf("unknown feed action %q, expected enable, disable or keep", action)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no feed with url %s", url.String)
+	}
+	if err != nil {
+		return fmt.Errorf("could not %s feed: %w", action, err)
+	}
+
+	if action == "keep" {
+		fmt.Printf("Feed %s keeps %s episode(s)\n", name, cmd.Args[2])
+		return nil
+	}
+	fmt.Printf("Feed %s %sd\n", name, action)
+	return nil
+}
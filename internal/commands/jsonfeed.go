@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            json.RawMessage  `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	ContentText   string           `json:"content_text"`
+	Summary       string           `json:"summary"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []JSONFeedAuthor `json:"authors"`
+	// Author is the JSON Feed 1.0 field, replaced by Authors in 1.1.
+	Author      *JSONFeedAuthor      `json:"author"`
+	Attachments []JSONFeedAttachment `json:"attachments"`
+	Tags        []string             `json:"tags"`
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// jsonFeedSniffLen is how far into the document we look for the opening brace.
+const jsonFeedSniffLen = 512
+
+// isJSONFeed checks the content type first and falls back to looking at the start of the
+// payload, since plenty of servers send JSON Feed as text/plain or application/json.
+func isJSONFeed(br *bufio.Reader, contentType string) bool {
+	if isJSONFeedMediaType(contentType) {
+		return true
+	}
+
+	// Peek returns what it has along with an error when the document is shorter
+	peeked, _ := br.Peek(jsonFeedSniffLen)
+	trimmed := bytes.TrimLeft(peeked, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func isJSONFeedMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/feed+json"
+}
+
+// parseJSONFeed decodes a JSON Feed document and maps it onto RSSFeed. Other JSON
+// documents are rejected unless the server said it's a JSON Feed.
+func parseJSONFeed(r io.Reader, contentType string) (*RSSFeed, error) {
+	var jf JSONFeed
+	err := json.NewDecoder(r).Decode(&jf)
+	if err != nil {
+		if errors.Is(err, ErrFeedTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error unmarshaling json feed: %w", err)
+	}
+	if !strings.HasPrefix(jf.Version, "https://jsonfeed.org/version/") && !isJSONFeedMediaType(contentType) {
+		return nil, fmt.Errorf("%w: json document without a JSON Feed version", ErrNotAFeed)
+	}
+
+	var feed RSSFeed
+	feed.Channel.Title = jf.Title
+	feed.Channel.Link = jf.HomePageURL
+	feed.Channel.Description = jf.Description
+
+	for _, item := range jf.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+		if link == "" {
+			// ids are often permalinks, which is the best we can do without a url
+			if id := item.id(); strings.HasPrefix(id, "http://") || strings.HasPrefix(id, "https://") {
+				link = id
+			}
+		}
+
+		content := item.ContentHTML
+		if content == "" {
+			content = textToHTML(item.ContentText)
+		}
+		// summary is plain text
+		description := textToHTML(item.Summary)
+		if description == "" {
+			description = content
+		}
+
+		date := item.DatePublished
+		if date == "" {
+			date = item.DateModified
+		}
+
+		rssItem := RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     rfc3339ToRSSDate(date),
+			Author:      item.authorNames(),
+			GUID:        item.id(),
+			Content:     content,
+			Categories:  item.Tags,
+		}
+		for _, attachment := range item.Attachments {
+			rssItem.Enclosures = append(rssItem.Enclosures, RSSEnclosure{
+				URL:    attachment.URL,
+				Type:   attachment.MimeType,
+				Length: strconv.FormatInt(attachment.SizeInBytes, 10),
+			})
+			// the item only has room for one duration, the first attachment's
+			if rssItem.Duration == "" && attachment.DurationInSeconds > 0 {
+				rssItem.Duration = strconv.FormatFloat(attachment.DurationInSeconds, 'f', -1, 64)
+			}
+		}
+		feed.Channel.Item = append(feed.Channel.Item, rssItem)
+	}
+
+	return &feed, nil
+}
+
+// id returns the item id as a string; the spec says it's a string but some feeds use numbers.
+func (item JSONFeedItem) id() string {
+	var s string
+	if err := json.Unmarshal(item.ID, &s); err == nil {
+		return s
+	}
+	return string(item.ID)
+}
+
+func (item JSONFeedItem) authorNames() string {
+	authors := item.Authors
+	if len(authors) == 0 && item.Author != nil {
+		authors = []JSONFeedAuthor{*item.Author}
+	}
+
+	var names []string
+	for _, author := range authors {
+		if author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// textToHTML marks up plain text, like content_text or an Atom text construct, so its
+// paragraphs and line breaks survive being stored and rendered as html.
+func textToHTML(text string) string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			lines := strings.Split(html.EscapeString(paragraph), "\n")
+			paragraphs = append(paragraphs, "<p>"+strings.Join(lines, "<br>")+"</p>")
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
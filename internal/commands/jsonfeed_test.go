@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONFeed(t *testing.T) {
+	const doc = `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "Site",
+	"home_page_url": "http://site/",
+	"items": [
+		{
+			"id": "1",
+			"url": "http://site/1",
+			"title": "First",
+			"content_html": "<p>Hello</p>",
+			"summary": "Hi",
+			"date_published": "2006-01-02T15:04:05Z",
+			"authors": [{"name": "Ann"}, {"name": "Bob"}]
+		},
+		{
+			"id": "http://site/2",
+			"title": "Second",
+			"content_text": "Plain",
+			"date_modified": "2006-01-03T15:04:05Z",
+			"author": {"name": "Cy"}
+		},
+		{
+			"id": 3,
+			"external_url": "http://elsewhere/3",
+			"summary": "Only a summary"
+		}
+	]
+}`
+
+	// served as text/plain, so it has to be sniffed
+	feed := parseFeedString(t, doc, "text/plain")
+	if feed.Channel.Title != "Site" || feed.Channel.Link != "http://site/" {
+		t.Errorf("channel = %q, %q", feed.Channel.Title, feed.Channel.Link)
+	}
+	if len(feed.Channel.Item) != 3 {
+		t.Fatalf("got %d items, want 3", len(feed.Channel.Item))
+	}
+
+	tests := []struct {
+		link, description, content, pubDate, author string
+	}{
+		{"http://site/1", "<p>Hi</p>", "<p>Hello</p>", "Mon, 02 Jan 2006 15:04:05 +0000", "Ann, Bob"},
+		{"http://site/2", "<p>Plain</p>", "<p>Plain</p>", "Tue, 03 Jan 2006 15:04:05 +0000", "Cy"},
+		{"http://elsewhere/3", "<p>Only a summary</p>", "", "", ""},
+	}
+	for i, want := range tests {
+		item := feed.Channel.Item[i]
+		if item.Link != want.link || item.Description != want.description || item.Content != want.content || item.PubDate != want.pubDate || item.Author != want.author {
+			t.Errorf("item %d = %+v, want %+v", i, item, want)
+		}
+	}
+}
+
+func TestIsJSONFeed(t *testing.T) {
+	tests := []struct {
+		body, contentType string
+		want              bool
+	}{
+		{`{}`, "application/feed+json; charset=utf-8", true},
+		{`{"version": "https://jsonfeed.org/version/1"}`, "application/json", true},
+		{`  {"version": "1.0"}`, "application/json", true},
+		{`<rss></rss>`, "text/xml", false},
+	}
+	for _, tt := range tests {
+		if got := isJSONFeed(bufio.NewReader(strings.NewReader(tt.body)), tt.contentType); got != tt.want {
+			t.Errorf("isJSONFeed(%q, %q) = %v, want %v", tt.body, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONFeedRejectsOtherJSON(t *testing.T) {
+	_, err := ParseFeed(strings.NewReader(`{"version": "1.0"}`), "application/json")
+	if !errors.Is(err, ErrNotAFeed) {
+		t.Errorf("ParseFeed error = %v, want ErrNotAFeed", err)
+	}
+}
+
+func TestParseJSONFeedEscapesText(t *testing.T) {
+	const doc = `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"items": [{"id": "1", "summary": "Use <b> for bold", "content_text": "x < y"}]
+}`
+
+	item := parseFeedString(t, doc, "application/feed+json").Channel.Item[0]
+	if item.Description != "<p>Use &lt;b&gt; for bold</p>" || item.Content != "<p>x &lt; y</p>" {
+		t.Errorf("description %q, content %q", item.Description, item.Content)
+	}
+}
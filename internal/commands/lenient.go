@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// newLenientDecoder returns an xml.Decoder that accepts the usual mistakes in feeds:
+// bare ampersands, html entities like &nbsp; and mismatched end tags. It is only used
+// after a strict parse failed. HTML auto-closing is left off on purpose: it would treat
+// <link> as an empty element and lose the rest of any RSS feed.
+func newLenientDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+// controlCharStripper drops the ASCII control characters XML doesn't allow. They are
+// single bytes that can't be part of a multi-byte UTF-8 sequence, so it is safe to
+// filter them byte by byte.
+type controlCharStripper struct {
+	r       io.Reader
+	removed int
+}
+
+func (s *controlCharStripper) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+				s.removed++
+				continue
+			}
+			p[kept] = b
+			kept++
+		}
+		// don't report 0 bytes without an error just because everything was stripped
+		if kept > 0 || err != nil || n == 0 {
+			return kept, err
+		}
+	}
+}
+
+// salvageError decides whether a decode error still leaves something worth keeping.
+// Items decoded before the error are kept by encoding/xml, so a truncated or broken
+// document with at least one item becomes a warning instead of a failure. A strict
+// decoder never salvages, ParseFeed retries leniently instead.
+func salvageError(decoder *xml.Decoder, err error, items int) (string, error) {
+	if err == nil {
+		return "", nil
+	}
+	if decoder.Strict || errors.Is(err, ErrFeedTooLarge) || items == 0 {
+		return "", err
+	}
+	return fmt.Sprintf("feed is malformed, kept the %d items before the error: %v", items, err), nil
+}
+
+// warning describes what was stripped, if anything.
+func (s *controlCharStripper) warning() string {
+	if s.removed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("removed %d invalid control characters", s.removed)
+}
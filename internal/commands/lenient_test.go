@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseFeedLenientEntities(t *testing.T) {
+	const doc = `<rss><channel><title>Tom & Jerry&nbsp;</title><link>http://site/</link>
+<item><title>First</title><link>http://site/1</link><guid>post-1</guid></item>
+</channel></rss>`
+
+	feed := parseFeedString(t, doc, "application/rss+xml")
+	if feed.Channel.Title != "Tom & Jerry\u00a0" || feed.Channel.Link != "http://site/" {
+		t.Errorf("channel = %q, %q", feed.Channel.Title, feed.Channel.Link)
+	}
+	if len(feed.Channel.Item) != 1 || feed.Channel.Item[0].Link != "http://site/1" {
+		t.Errorf("items = %+v", feed.Channel.Item)
+	}
+}
+
+func TestParseFeedSalvagesTruncatedFeed(t *testing.T) {
+	const doc = `<rss><channel><title>Site</title>
+<item><title>First</title><guid>post-1</guid></item>
+<item><title>Sec`
+
+	feed := parseFeedString(t, doc, "application/rss+xml")
+	if len(feed.Channel.Item) == 0 || feed.Channel.Item[0].GUID != "post-1" {
+		t.Fatalf("items = %+v, want the first item kept", feed.Channel.Item)
+	}
+	if len(feed.Warnings) == 0 {
+		t.Error("expected a warning for the truncated document")
+	}
+
+	if _, err := ParseFeed(strings.NewReader(`<rss><channel><title>Site`), "application/rss+xml"); err == nil {
+		t.Error("a broken feed without items should fail")
+	}
+}
+
+func TestControlCharStripper(t *testing.T) {
+	stripper := &controlCharStripper{r: strings.NewReader("a\x00b\x0bc\td\n")}
+	got, err := io.ReadAll(stripper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abc\td\n" {
+		t.Errorf("got %q", got)
+	}
+	if stripper.warning() != "removed 2 invalid control characters" {
+		t.Errorf("warning = %q", stripper.warning())
+	}
+}
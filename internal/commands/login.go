@@ -9,13 +9,13 @@ import (
 
 // Create a login handler function:
 // This will be the function signature of all command handlers.
-func HandlerLogin(s *config.State, cmd Command) error {
+func HandlerLogin(ctx context.Context, s *config.State, cmd Command) error {
 	if len(cmd.Args) < 1 {
 		return fmt.Errorf("the login handler expects a single argument, the username")
 	}
 	userName := cmd.Args[0]
 
-	_, err := s.Db.GetUser(context.Background(), userName)
+	_, err := s.Db.GetUser(ctx, userName)
 	if err != nil {
 		return fmt.Errorf("user does not exist: %v", err)
 	}
@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// RSSEnclosure is an RSS <enclosure>, or an Atom rel="enclosure" link or JSON Feed
+// attachment mapped onto it.
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// PodcastLink is a Podcasting 2.0 element that points at a file, like a transcript.
+type PodcastLink struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// PodcastInfo is the episode metadata podcast feeds add to each item. It's embedded
+// in RSSItem.
+type PodcastInfo struct {
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Image    struct {
+		Href string `xml:"href,attr"`
+	} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	Episode        string        `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	Season         string        `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	PodcastEpisode string        `xml:"https://podcastindex.org/namespace/1.0 episode"`
+	PodcastSeason  string        `xml:"https://podcastindex.org/namespace/1.0 season"`
+	Transcripts    []PodcastLink `xml:"https://podcastindex.org/namespace/1.0 transcript"`
+	Chapters       PodcastLink   `xml:"https://podcastindex.org/namespace/1.0 chapters"`
+}
+
+// enclosureParams turns the item's enclosures into SaveEnclosure rows. The episode
+// metadata belongs to the item, so every enclosure gets the same copy.
+func (item RSSItem) enclosureParams(feedID uuid.UUID, now time.Time) []database.SaveEnclosureParams {
+	var duration sql.NullInt32
+	if d, ok := parseDuration(item.Duration); ok {
+		duration = sql.NullInt32{Int32: int32(d.Seconds()), Valid: true}
+	}
+	episode := nullInt32(item.Episode)
+	if !episode.Valid {
+		episode = nullInt32(item.PodcastEpisode)
+	}
+	season := nullInt32(item.Season)
+	if !season.Valid {
+		season = nullInt32(item.PodcastSeason)
+	}
+	var transcript string
+	if len(item.Transcripts) > 0 {
+		transcript = item.Transcripts[0].URL
+	}
+
+	var params []database.SaveEnclosureParams
+	for _, enclosure := range item.Enclosures {
+		url := strings.TrimSpace(enclosure.URL)
+		if url == "" {
+			continue
+		}
+		var length sql.NullInt64
+		if n, err := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64); err == nil && n > 0 {
+			length = sql.NullInt64{Int64: n, Valid: true}
+		}
+		params = append(params, database.SaveEnclosureParams{
+			ID:              uuid.New(),
+			Now:             now,
+			Url:             url,
+			MimeType:        strings.TrimSpace(enclosure.Type),
+			Length:          length,
+			DurationSeconds: duration,
+			ImageUrl:        nullString(item.Image.Href),
+			Episode:         episode,
+			Season:          season,
+			TranscriptUrl:   nullString(transcript),
+			ChaptersUrl:     nullString(item.Chapters.URL),
+			FeedID:          uuid.NullUUID{UUID: feedID, Valid: true},
+			Guid:            item.PostGUID(),
+		})
+	}
+	return params
+}
+
+// parseDuration reads itunes:duration, which is seconds or [HH:]MM:SS.
+func parseDuration(raw string) (time.Duration, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, false
+	}
+	var seconds float64
+	for _, part := range strings.Split(raw, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+func nullInt32(raw string) sql.NullInt32 {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 0 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(n), Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	s = strings.TrimSpace(s)
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// printEnclosures prints a post's enclosures and its episode metadata, each line
+// starting with prefix.
+func printEnclosures(enclosures []database.Enclosure, prefix string) {
+	if len(enclosures) == 0 {
+		return
+	}
+	// the metadata is the same on every enclosure of a post
+	first := enclosures[0]
+	if episode := formatEpisodeNumber(first.Season, first.Episode); episode != "" {
+		fmt.Printf("%sEpisode: %s\n", prefix, episode)
+	}
+	for _, enclosure := range enclosures {
+		fmt.Printf("%sEnclosure: %s\n", prefix, stripControlChars(formatEnclosure(enclosure)))
+	}
+	if first.ImageUrl.Valid {
+		fmt.Printf("%sImage: %s\n", prefix, stripControlChars(first.ImageUrl.String))
+	}
+	if first.TranscriptUrl.Valid {
+		fmt.Printf("%sTranscript: %s\n", prefix, stripControlChars(first.TranscriptUrl.String))
+	}
+	if first.ChaptersUrl.Valid {
+		fmt.Printf("%sChapters: %s\n", prefix, stripControlChars(first.ChaptersUrl.String))
+	}
+}
+
+// formatEpisodeNumber prints S2E5, E5 or "" when the feed doesn't number its episodes.
+func formatEpisodeNumber(season, episode sql.NullInt32) string {
+	switch {
+	case season.Valid && episode.Valid:
+		return fmt.Sprintf("S%dE%d", season.Int32, episode.Int32)
+	case episode.Valid:
+		return fmt.Sprintf("E%d", episode.Int32)
+	}
+	return ""
+}
+
+// formatEnclosure is the one-line summary browse prints for an enclosure.
+func formatEnclosure(e database.Enclosure) string {
+	var details []string
+	if e.MimeType != "" {
+		details = append(details, e.MimeType)
+	}
+	if e.Length.Valid && e.Length.Int64 > 0 {
+		details = append(details, formatBytes(e.Length.Int64))
+	}
+	if e.DurationSeconds.Valid {
+		details = append(details, formatEpisodeDuration(time.Duration(e.DurationSeconds.Int32)*time.Second))
+	}
+	if len(details) == 0 {
+		return e.Url
+	}
+	return fmt.Sprintf("%s (%s)", e.Url, strings.Join(details, ", "))
+}
+
+// formatEpisodeDuration prints 1:02:03 or 42:10.
+func formatEpisodeDuration(d time.Duration) string {
+	total := int(d.Seconds())
+	h, m, s := total/3600, total/60%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"90", 90 * time.Second, true},
+		{"12:05", 12*time.Minute + 5*time.Second, true},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, true},
+		{"1.5", 1500 * time.Millisecond, true},
+		{"1:xx", 0, false},
+		{"-5", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDuration(tt.raw)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPodcastEnclosures(t *testing.T) {
+	const doc = `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+<channel>
+<title>Show</title>
+<item>
+<title>Episode 3</title>
+<guid>ep-3</guid>
+<enclosure url="http://site/ep3.mp3" type="audio/mpeg" length="1234"/>
+<enclosure url="" type="audio/mpeg"/>
+<itunes:duration>01:02:03</itunes:duration>
+<itunes:image href="http://site/ep3.jpg"/>
+<podcast:episode>3</podcast:episode>
+<itunes:season>2</itunes:season>
+<podcast:transcript url="http://site/ep3.vtt" type="text/vtt"/>
+<podcast:chapters url="http://site/ep3.json" type="application/json+chapters"/>
+</item>
+</channel>
+</rss>`
+
+	feed := parseFeedString(t, doc, "application/rss+xml")
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+	params := feed.Channel.Item[0].enclosureParams(uuid.New(), time.Now())
+	if len(params) != 1 {
+		t.Fatalf("got %d enclosures, want 1", len(params))
+	}
+	p := params[0]
+	if p.Url != "http://site/ep3.mp3" || p.MimeType != "audio/mpeg" || p.Length.Int64 != 1234 {
+		t.Errorf("enclosure = %q, %q, %v", p.Url, p.MimeType, p.Length)
+	}
+	if p.DurationSeconds.Int32 != 3723 || p.Episode.Int32 != 3 || p.Season.Int32 != 2 {
+		t.Errorf("duration %v, episode %v, season %v", p.DurationSeconds, p.Episode, p.Season)
+	}
+	if p.ImageUrl.String != "http://site/ep3.jpg" || p.TranscriptUrl.String != "http://site/ep3.vtt" || p.ChaptersUrl.String != "http://site/ep3.json" {
+		t.Errorf("image %v, transcript %v, chapters %v", p.ImageUrl, p.TranscriptUrl, p.ChaptersUrl)
+	}
+}
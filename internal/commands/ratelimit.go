@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/config"
+)
+
+// Defaults for the per-host limits when .gatorconfig.json doesn't set them.
+const (
+	defaultHostConcurrency     = 2
+	defaultHostRequestInterval = time.Second
+	// maxHostWait is the longest a fetch waits for a host that asked us to back off.
+	// Anything longer fails the fetch with ErrHostThrottled so the worker can move on
+	// to other hosts.
+	maxHostWait = 30 * time.Second
+)
+
+// RetryAfterError is returned when the server asked us to come back later.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.RetryAfter)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// hostLimiterFromConfig builds a hostLimiter from the host_* settings in .gatorconfig.json.
+func hostLimiterFromConfig(c *config.Config) (*hostLimiter, error) {
+	concurrency := defaultHostConcurrency
+	if c.HostConcurrency > 0 {
+		concurrency = c.HostConcurrency
+	}
+
+	interval, err := parseConfigDuration("host_request_interval", c.HostRequestInterval, defaultHostRequestInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHostLimiter(concurrency, interval), nil
+}
+
+// hostLimiter caps concurrent requests per host and spaces out their start times.
+type hostLimiter struct {
+	concurrency int
+	interval    time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots chan struct{}
+	// next is the earliest time the next request may start.
+	next time.Time
+}
+
+func newHostLimiter(concurrency int, interval time.Duration) *hostLimiter {
+	return &hostLimiter{
+		concurrency: concurrency,
+		interval:    interval,
+		hosts:       make(map[string]*hostState),
+	}
+}
+
+func (l *hostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, ok := l.hosts[host]
+	if !ok {
+		st = &hostState{slots: make(chan struct{}, l.concurrency)}
+		l.hosts[host] = st
+	}
+	return st
+}
+
+// acquire blocks until a request to host may start and returns a func to call once
+// the request is done.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	st := l.state(host)
+
+	select {
+	case st.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-st.slots }
+
+	l.mu.Lock()
+	now := time.Now()
+	start := st.next
+	if start.Before(now) {
+		start = now
+	}
+	wait := start.Sub(now)
+	if wait > maxHostWait {
+		l.mu.Unlock()
+		release()
+		return nil, &RetryAfterError{
+			Err:        fmt.Errorf("%w: next request to %s can't start for %s", ErrHostThrottled, host, wait.Round(time.Second)),
+			RetryAfter: wait,
+		}
+	}
+	st.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// backOff keeps requests to host from starting before until.
+func (l *hostLimiter) backOff(host string, until time.Time) {
+	st := l.state(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(st.next) {
+		st.next = until
+	}
+}
+
+// retryAfter parses a Retry-After header, which is either seconds or an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// retryAfterFromError returns how long the server asked us to wait, if it did.
+func retryAfterFromError(err error) time.Duration {
+	var retryErr *RetryAfterError
+	if errors.As(err, &retryErr) {
+		return retryErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterThrottles(t *testing.T) {
+	limiter := newHostLimiter(1, time.Millisecond)
+	limiter.backOff("site", time.Now().Add(time.Hour))
+
+	_, err := limiter.acquire(context.Background(), "site")
+	if !errors.Is(err, ErrHostThrottled) || errors.Is(err, ErrFeedRateLimited) {
+		t.Fatalf("acquire error = %v, want ErrHostThrottled", err)
+	}
+	if wait := retryAfterFromError(err); wait < 59*time.Minute {
+		t.Errorf("retry after %v, want about an hour", wait)
+	}
+
+	// other hosts and the slot are unaffected
+	release, err := limiter.acquire(context.Background(), "other")
+	if err != nil {
+		t.Fatalf("acquire other host: %v", err)
+	}
+	release()
+}
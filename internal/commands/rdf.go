@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// RDFFeed is an RSS 1.0 document. Unlike RSS 2.0 the items are siblings of the channel.
+type RDFFeed struct {
+	Channel struct {
+		Title           string `xml:"http://purl.org/rss/1.0/ title"`
+		Link            string `xml:"http://purl.org/rss/1.0/ link"`
+		Description     string `xml:"http://purl.org/rss/1.0/ description"`
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"http://purl.org/rss/1.0/ channel"`
+	Items []RDFItem `xml:"http://purl.org/rss/1.0/ item"`
+}
+
+type RDFItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"http://purl.org/rss/1.0/ title"`
+	Link        string   `xml:"http://purl.org/rss/1.0/ link"`
+	Description string   `xml:"http://purl.org/rss/1.0/ description"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+// parseRDF decodes an RSS 1.0 document and maps it onto RSSFeed.
+func parseRDF(decoder *xml.Decoder, start xml.StartElement) (*RSSFeed, error) {
+	var rdf RDFFeed
+	err := decoder.DecodeElement(&rdf, &start)
+	warning, err := salvageError(decoder, err, len(rdf.Items))
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling rdf: %w", err)
+	}
+
+	var feed RSSFeed
+	if warning != "" {
+		feed.Warnings = append(feed.Warnings, warning)
+	}
+	feed.Channel.Title = strings.TrimSpace(rdf.Channel.Title)
+	feed.Channel.Link = strings.TrimSpace(rdf.Channel.Link)
+	feed.Channel.Description = strings.TrimSpace(rdf.Channel.Description)
+	feed.Channel.UpdatePeriod = rdf.Channel.UpdatePeriod
+	feed.Channel.UpdateFrequency = rdf.Channel.UpdateFrequency
+
+	for _, item := range rdf.Items {
+		link := strings.TrimSpace(item.Link)
+		if link == "" {
+			// rdf:about is required and is normally the same as link
+			link = item.About
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       strings.TrimSpace(item.Title),
+			Link:        link,
+			Description: strings.TrimSpace(item.Description),
+			PubDate:     rfc3339ToRSSDate(item.Date),
+			Author:      strings.TrimSpace(item.Creator),
+			GUID:        item.About,
+			Content:     strings.TrimSpace(item.Content),
+			Categories:  item.Subjects,
+		})
+	}
+
+	UnescapeHTML(&feed)
+	return &feed, nil
+}
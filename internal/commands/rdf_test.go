@@ -0,0 +1,45 @@
+package commands
+
+import "testing"
+
+func TestParseRDF(t *testing.T) {
+	const doc = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="http://site/">
+<title>Site</title>
+<link>http://site/</link>
+<description>All the posts</description>
+</channel>
+<item rdf:about="http://site/1">
+<title>First</title>
+<link>http://site/1</link>
+<description>One</description>
+<dc:date>2006-01-02T15:04:05Z</dc:date>
+<dc:creator>Ann</dc:creator>
+</item>
+<item rdf:about="http://site/2">
+<title>Second</title>
+<dc:date>2006-01-03</dc:date>
+</item>
+</rdf:RDF>`
+
+	feed := parseFeedString(t, doc, "application/rdf+xml")
+	if feed.Channel.Title != "Site" || feed.Channel.Link != "http://site/" || feed.Channel.Description != "All the posts" {
+		t.Errorf("channel = %q, %q, %q", feed.Channel.Title, feed.Channel.Link, feed.Channel.Description)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Item))
+	}
+
+	first := feed.Channel.Item[0]
+	if first.Title != "First" || first.Link != "http://site/1" || first.Description != "One" || first.Author != "Ann" {
+		t.Errorf("first item = %+v", first)
+	}
+	if first.PubDate != "Mon, 02 Jan 2006 15:04:05 +0000" {
+		t.Errorf("first pubDate = %q", first.PubDate)
+	}
+	// without <link> the item falls back to rdf:about
+	if second := feed.Channel.Item[1]; second.Link != "http://site/2" {
+		t.Errorf("second link = %q", second.Link)
+	}
+}
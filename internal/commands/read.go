@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/boxy-pug/gator/internal/config"
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// summaryLength is how much of the description browse shows; read shows all of it.
+const summaryLength = 200
+
+// summarize collapses whitespace and cuts text to at most n runes.
+func summarize(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:n])) + "..."
+}
+
+// HandlerRead prints a whole post, found by its id or url, from a feed the user follows.
+func HandlerRead(ctx context.Context, s *config.State, cmd Command, user database.User) error {
+	if len(cmd.Args) < 1 {
+		return fmt.Errorf("usage: read <post url or id>")
+	}
+
+	post, err := s.Db.GetPostForUser(ctx, database.GetPostForUserParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Post:   cmd.Args[0],
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no post %s in the feeds you follow", cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("error retrieving post: %w", err)
+	}
+
+	enclosures, err := s.Db.GetEnclosuresForPosts(ctx, []uuid.UUID{post.ID})
+	if err != nil {
+		return fmt.Errorf("error retrieving enclosures: %w", err)
+	}
+
+	fmt.Printf("%s\n", stripControlChars(post.Title))
+	fmt.Printf("Feed: %s\n", stripControlChars(post.FeedName))
+	if post.Author.Valid {
+		fmt.Printf("Author: %s\n", stripControlChars(post.Author.String))
+	}
+	if post.PublishedAt.Valid {
+		fmt.Printf("Published At: %v\n", post.PublishedAt.Time)
+	}
+	fmt.Printf("URL: %s\n", stripControlChars(post.Url))
+	if len(post.Categories) > 0 {
+		fmt.Printf("Categories: %s\n", stripControlChars(strings.Join(post.Categories, ", ")))
+	}
+	printEnclosures(enclosures, "")
+	fmt.Println()
+
+	// feeds without a separate content only have the description
+	body := post.Content.String
+	if body == "" {
+		body = post.Description.String
+	}
+	fmt.Println(RenderHTML(body, terminalWidth()))
+	return nil
+}
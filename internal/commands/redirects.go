@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/boxy-pug/gator/internal/config"
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// moveFeed points a feed at the URL it was permanently redirected to. If another feed
+// already has that URL, follows and posts are merged into it and this feed is deleted.
+// Either way the old URL is kept as an alias, and the surviving feed is returned.
+func moveFeed(ctx context.Context, s *config.State, feed database.Feed, newURL string) (database.Feed, error) {
+	tx, err := s.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := s.Db.WithTx(tx)
+
+	// downloads of posts the target already had are deleted with the source feed
+	var orphaned []string
+	targetID, err := qtx.GetFeedByUrl(ctx, sql.NullString{String: newURL, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) || targetID == feed.ID {
+		targetID = feed.ID
+		err = qtx.UpdateFeedUrl(ctx, database.UpdateFeedUrlParams{
+			ID:  feed.ID,
+			Url: sql.NullString{String: newURL, Valid: true},
+		})
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("could not update feed url: %w", err)
+		}
+		log.Printf("Feed %s moved permanently to %s.", feed.Name, newURL)
+	} else if err != nil {
+		return database.Feed{}, fmt.Errorf("could not look up redirect target: %w", err)
+	} else {
+		orphaned, err = mergeFeed(ctx, qtx, feed.ID, targetID)
+		if err != nil {
+			return database.Feed{}, err
+		}
+		log.Printf("Feed %s moved permanently to %s, which we already had. Merged them.", feed.Name, newURL)
+	}
+
+	err = qtx.CreateFeedAlias(ctx, database.CreateFeedAliasParams{
+		Url:    feed.Url.String,
+		FeedID: targetID,
+	})
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("could not create feed alias: %w", err)
+	}
+
+	target, err := qtx.GetFeedById(ctx, targetID)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("could not load feed %s moved to: %w", newURL, err)
+	}
+	// the server sends this feed's followers there now, so a disabled or gone target
+	// has to be fetched again
+	if target.Status != FeedStatusActive {
+		_, err = qtx.EnableFeed(ctx, target.Url)
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("could not enable feed %s moved to: %w", newURL, err)
+		}
+		log.Printf("Re-enabled feed %s (was %s), %s redirects to it.", target.Name, target.Status, feed.Name)
+		target.Status = FeedStatusActive
+		target.ConsecutiveFailures = 0
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("could not commit feed move: %w", err)
+	}
+
+	for _, filePath := range orphaned {
+		if err := removeDownloadFiles(filePath); err != nil {
+			log.Printf("Error deleting download of merged feed %s: %v", feed.Name, err)
+		}
+	}
+	return target, nil
+}
+
+// mergeFeed moves everything from source over to target and deletes source. It returns
+// the paths of the downloads deleted along with source's leftover posts, for the caller
+// to remove once the merge is committed.
+func mergeFeed(ctx context.Context, qtx *database.Queries, sourceID, targetID uuid.UUID) ([]string, error) {
+	source := uuid.NullUUID{UUID: sourceID, Valid: true}
+	target := uuid.NullUUID{UUID: targetID, Valid: true}
+
+	err := qtx.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{SourceID: source, TargetID: target})
+	if err != nil {
+		return nil, fmt.Errorf("could not move follows: %w", err)
+	}
+	err = qtx.MoveFeedPosts(ctx, database.MoveFeedPostsParams{SourceID: source, TargetID: target})
+	if err != nil {
+		return nil, fmt.Errorf("could not move posts: %w", err)
+	}
+	err = qtx.MoveFeedAliases(ctx, database.MoveFeedAliasesParams{SourceID: sourceID, TargetID: targetID})
+	if err != nil {
+		return nil, fmt.Errorf("could not move aliases: %w", err)
+	}
+	err = qtx.MoveFeedFetchLog(ctx, database.MoveFeedFetchLogParams{SourceID: sourceID, TargetID: targetID})
+	if err != nil {
+		return nil, fmt.Errorf("could not move fetch log: %w", err)
+	}
+	orphaned, err := qtx.GetFeedDownloadPaths(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("could not list downloads of merged feed: %w", err)
+	}
+	// follows and posts the target already had go with the source
+	err = qtx.DeleteFeed(ctx, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete merged feed: %w", err)
+	}
+	return orphaned, nil
+}
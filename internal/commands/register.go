@@ -11,14 +11,14 @@ import (
 	"github.com/google/uuid"
 )
 
-func HandlerRegister(s *config.State, cmd Command) error {
+func HandlerRegister(ctx context.Context, s *config.State, cmd Command) error {
 	if len(cmd.Args) < 1 {
 		return fmt.Errorf("the register command expects a username")
 	}
 
 	userName := cmd.Args[0]
 
-	_, err := s.Db.GetUser(context.Background(), userName)
+	_, err := s.Db.GetUser(ctx, userName)
 	if err == nil {
 		return fmt.Errorf("user already exists: %v", err)
 	}
@@ -27,7 +27,7 @@ func HandlerRegister(s *config.State, cmd Command) error {
 	createdAt := time.Now()
 	updatedAt := createdAt
 
-	_, err = s.Db.CreateUser(context.Background(), database.CreateUserParams{
+	_, err = s.Db.CreateUser(ctx, database.CreateUserParams{
 		ID:        userId,
 		Name:      userName,
 		CreatedAt: createdAt,
@@ -37,7 +37,7 @@ func HandlerRegister(s *config.State, cmd Command) error {
 	// set current user in config:
 	err = s.Config.SetUser(userName)
 	if err != nil {
-		fmt.Errorf("error setting username in config")
+		return fmt.Errorf("error setting username in config: %v", err)
 	}
 	fmt.Printf("User %s has been created successfully\n", userName)
 	log.Printf("User created: %v, %v, %v\n", userId, userName, createdAt)
@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// defaultTextWidth is used when $COLUMNS doesn't tell us how wide the terminal is.
+const defaultTextWidth = 80
+
+// terminalWidth reads $COLUMNS, which most shells set for interactive sessions.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 20 {
+		return columns
+	}
+	return defaultTextWidth
+}
+
+// blockElements start on a new line. paragraphElements also get a blank line around them.
+var (
+	blockElements = map[string]bool{
+		"li": true, "dt": true, "dd": true, "tr": true, "div": true, "figcaption": true,
+		"caption": true,
+	}
+	paragraphElements = map[string]bool{
+		"p": true, "pre": true, "blockquote": true, "ul": true, "ol": true, "dl": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"figure": true, "table": true, "hr": true,
+	}
+)
+
+// RenderHTML turns a post body into plain text for the terminal: paragraphs wrapped to
+// width, lists and quotes indented, and links numbered with their URLs listed at the end.
+func RenderHTML(src string, width int) string {
+	r := &textRenderer{width: width, footnotes: true, linkNumbers: map[string]int{}}
+	return r.render(src)
+}
+
+// htmlToText is RenderHTML without wrapping or footnotes, for one-line summaries.
+func htmlToText(src string) string {
+	r := &textRenderer{linkNumbers: map[string]int{}}
+	return r.render(src)
+}
+
+type textRenderer struct {
+	width     int
+	footnotes bool
+
+	out strings.Builder
+	// inline collects the text of the block being built
+	inline strings.Builder
+	// prefixes indent nested quotes and lists; bullet is the marker for the next line
+	prefixes []string
+	bullet   string
+	lists    []int
+	pre      int
+
+	links       []string
+	linkNumbers map[string]int
+}
+
+func (r *textRenderer) render(src string) string {
+	root, err := parseHTMLFragment(src)
+	if err != nil {
+		return stripControlChars(src)
+	}
+	r.walk(root)
+	r.flush()
+
+	text := strings.TrimSpace(r.out.String())
+	if r.footnotes && len(r.links) > 0 {
+		var notes strings.Builder
+		for i, link := range r.links {
+			fmt.Fprintf(&notes, "[%d] %s\n", i+1, link)
+		}
+		text += "\n\n" + strings.TrimSpace(notes.String())
+	}
+	// posts stored before they were sanitized can still hold escape sequences
+	return stripControlChars(text)
+}
+
+func (r *textRenderer) walk(n *nethtml.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case nethtml.TextNode:
+			r.inline.WriteString(c.Data)
+		case nethtml.ElementNode:
+			// stored posts are sanitized already, but older rows may still hold scripts and styles
+			if !droppedElements[c.Data] {
+				r.element(c)
+			}
+		}
+	}
+}
+
+func (r *textRenderer) element(n *nethtml.Node) {
+	// a list inside a list item continues it rather than starting a new paragraph
+	nestedList := (n.Data == "ul" || n.Data == "ol") && len(r.lists) > 0
+	switch {
+	case paragraphElements[n.Data] && !nestedList:
+		r.flush()
+		r.blankLine()
+	case blockElements[n.Data] || nestedList:
+		r.flush()
+	}
+
+	switch n.Data {
+	case "br":
+		r.newLine()
+		return
+	case "hr":
+		r.out.WriteString(strings.Repeat("-", 20) + "\n")
+		r.blankLine()
+		return
+	case "img":
+		alt := strings.TrimSpace(attr(n, "alt"))
+		if alt == "" {
+			alt = "image"
+		}
+		r.inline.WriteString(" [" + alt + "]" + r.footnote(attr(n, "src")) + " ")
+		return
+	case "pre":
+		r.pre++
+		r.walk(n)
+		r.flush()
+		r.pre--
+		r.blankLine()
+		return
+	case "blockquote":
+		r.prefixes = append(r.prefixes, "> ")
+		r.walk(n)
+		r.flush()
+		r.prefixes = r.prefixes[:len(r.prefixes)-1]
+		r.blankLine()
+		return
+	case "ul", "ol":
+		start := 1
+		if s, err := strconv.Atoi(attr(n, "start")); err == nil {
+			start = s
+		}
+		if n.Data == "ul" {
+			start = 0
+		}
+		r.lists = append(r.lists, start)
+		r.walk(n)
+		r.flush()
+		r.lists = r.lists[:len(r.lists)-1]
+		if !nestedList {
+			r.blankLine()
+		}
+		return
+	case "li":
+		r.listItem(n)
+		return
+	case "a":
+		r.walk(n)
+		r.inline.WriteString(r.footnote(attr(n, "href")))
+		return
+	case "td", "th":
+		r.walk(n)
+		r.inline.WriteString("  ")
+		return
+	}
+
+	r.walk(n)
+	if paragraphElements[n.Data] {
+		r.flush()
+		r.blankLine()
+	} else if blockElements[n.Data] {
+		r.flush()
+	}
+}
+
+// listItem renders an <li> with its bullet or number and indents what wraps under it.
+func (r *textRenderer) listItem(n *nethtml.Node) {
+	bullet := "* "
+	if depth := len(r.lists); depth > 0 && r.lists[depth-1] > 0 {
+		bullet = strconv.Itoa(r.lists[depth-1]) + ". "
+		r.lists[depth-1]++
+	}
+	r.bullet = bullet
+	r.prefixes = append(r.prefixes, strings.Repeat(" ", len(bullet)))
+	r.walk(n)
+	r.flush()
+	r.prefixes = r.prefixes[:len(r.prefixes)-1]
+	r.bullet = ""
+}
+
+// footnote returns the [n] marker for link, numbering each distinct URL once.
+func (r *textRenderer) footnote(link string) string {
+	if !r.footnotes || link == "" || strings.HasPrefix(link, "#") {
+		return ""
+	}
+	number, ok := r.linkNumbers[link]
+	if !ok {
+		r.links = append(r.links, link)
+		number = len(r.links)
+		r.linkNumbers[link] = number
+	}
+	return fmt.Sprintf("[%d]", number)
+}
+
+func (r *textRenderer) newLine() {
+	r.inline.WriteString("\n")
+}
+
+// blankLine separates paragraphs, without stacking up blank lines.
+func (r *textRenderer) blankLine() {
+	text := r.out.String()
+	if text != "" && !strings.HasSuffix(text, "\n\n") {
+		r.out.WriteString("\n")
+	}
+}
+
+func (r *textRenderer) lineWidth() int {
+	if r.width <= 0 {
+		return 0
+	}
+	indent := utf8.RuneCountInString(strings.Join(r.prefixes, ""))
+	return max(r.width-indent, 20)
+}
+
+// flush writes the collected inline text as wrapped lines with the current prefixes.
+func (r *textRenderer) flush() {
+	text := r.inline.String()
+	r.inline.Reset()
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	var lines []string
+	if r.pre > 0 {
+		lines = strings.Split(strings.Trim(text, "\n"), "\n")
+	} else {
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, wrapText(line, r.lineWidth())...)
+		}
+	}
+
+	// the bullet takes the place of the innermost indent on the first line
+	indent := strings.Join(r.prefixes, "")
+	first := indent
+	if r.bullet != "" {
+		first = strings.Join(r.prefixes[:len(r.prefixes)-1], "") + r.bullet
+		r.bullet = ""
+	}
+	for i, line := range lines {
+		prefix := indent
+		if i == 0 {
+			prefix = first
+		}
+		r.out.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+	}
+}
+
+// wrapText collapses whitespace and breaks text into lines of at most width runes.
+// Words longer than width get a line of their own. A width of 0 means no wrapping.
+func wrapText(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	if width <= 0 {
+		return []string{strings.Join(words, " ")}
+	}
+
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}
+
+func attr(n *nethtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
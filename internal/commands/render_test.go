@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTMLStripsControlChars(t *testing.T) {
+	for _, src := range []string{
+		"<p>red&#27;[31m text&#155;2J</p>",
+		"<p>red\x1b[31m text\x1b[2J</p>",
+		sanitizeHTML("<p>red&#27;[31m text</p>", nil),
+	} {
+		text := RenderHTML(src, 80)
+		if strings.ContainsAny(text, "\x1b\u009b") {
+			t.Errorf("RenderHTML(%q) = %q, still has control characters", src, text)
+		}
+	}
+}
+
+func TestRenderHTMLSkipsDroppedElements(t *testing.T) {
+	src := `<style>p { color: red }</style><p>Hi<script>alert(1)</script></p><noscript>enable js</noscript>`
+	if got := RenderHTML(src, 80); got != "Hi" {
+		t.Errorf("RenderHTML = %q, want %q", got, "Hi")
+	}
+	if got := htmlToText(src); got != "Hi" {
+		t.Errorf("htmlToText = %q, want %q", got, "Hi")
+	}
+}
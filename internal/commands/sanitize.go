@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/boxy-pug/gator/internal/config"
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// droppedElements are removed together with everything inside them.
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "form": true, "input": true,
+	"button": true, "textarea": true, "select": true, "noscript": true, "template": true,
+	"svg": true, "math": true, "head": true, "meta": true, "link": true, "base": true,
+	"title": true,
+}
+
+// allowedElements are kept along with allowedAttrs. Anything else is replaced by its children.
+var allowedElements = map[string]bool{
+	"p": true, "br": true, "a": true, "b": true, "strong": true, "i": true, "em": true,
+	"u": true, "s": true, "del": true, "ins": true, "mark": true, "small": true, "sub": true,
+	"sup": true, "code": true, "pre": true, "blockquote": true, "q": true, "cite": true,
+	"abbr": true, "time": true, "ul": true, "ol": true, "li": true, "dl": true, "dt": true,
+	"dd": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"img": true, "figure": true, "figcaption": true, "hr": true, "table": true,
+	"thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true,
+	"caption": true, "div": true, "span": true,
+}
+
+var allowedAttrs = map[string]map[string]bool{
+	"a":    {"href": true, "title": true},
+	"img":  {"src": true, "alt": true, "title": true, "width": true, "height": true},
+	"abbr": {"title": true},
+	"time": {"datetime": true},
+	"td":   {"colspan": true, "rowspan": true},
+	"th":   {"colspan": true, "rowspan": true},
+	"ol":   {"start": true},
+}
+
+// urlAttrs hold links, which are resolved against the post and limited to safe schemes.
+var urlAttrs = map[string]bool{"href": true, "src": true}
+
+// SanitizeHTML cleans the descriptions and contents of a feed before they are stored.
+// Relative links are resolved against the item link, or feedURL when it has none.
+// Control characters are removed from the plain text fields too.
+func SanitizeHTML(feed *RSSFeed, feedURL string) {
+	feed.Channel.Title = stripControlChars(feed.Channel.Title)
+	feed.Channel.Description = stripControlChars(feed.Channel.Description)
+	feedBase, _ := url.Parse(feedURL)
+	for i := range feed.Channel.Item {
+		item := &feed.Channel.Item[i]
+		item.Title = stripControlChars(item.Title)
+		item.Author = stripControlChars(item.Author)
+		item.Creator = stripControlChars(item.Creator)
+		for j := range item.Categories {
+			item.Categories[j] = stripControlChars(item.Categories[j])
+		}
+		base := postBase(feedBase, item.PostURL())
+		item.Description = sanitizeHTML(item.Description, base)
+		item.Content = sanitizeHTML(item.Content, base)
+	}
+}
+
+// postBase is what relative links in a post resolve against: its own link, made
+// absolute against the feed URL if needed.
+func postBase(feedBase *url.URL, postURL string) *url.URL {
+	link, err := url.Parse(strings.TrimSpace(postURL))
+	if err != nil {
+		return feedBase
+	}
+	if link.IsAbs() {
+		return link
+	}
+	if feedBase != nil {
+		return feedBase.ResolveReference(link)
+	}
+	return feedBase
+}
+
+// sanitizeHTML keeps the markup in allowedElements and allowedAttrs and drops the rest,
+// so stored posts can't carry scripts, styles, forms or javascript: links.
+func sanitizeHTML(src string, base *url.URL) string {
+	if strings.TrimSpace(src) == "" {
+		return ""
+	}
+	root, err := parseHTMLFragment(src)
+	if err != nil {
+		// the html parser doesn't fail on bad markup, only on read errors
+		return html.EscapeString(src)
+	}
+	sanitizeChildren(root, base)
+
+	var buf bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := nethtml.Render(&buf, c); err != nil {
+			return html.EscapeString(src)
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// parseHTMLFragment parses src as the contents of a <body> and returns a node holding it.
+func parseHTMLFragment(src string) (*nethtml.Node, error) {
+	body := &nethtml.Node{Type: nethtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := nethtml.ParseFragment(strings.NewReader(src), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	return body, nil
+}
+
+func sanitizeChildren(n *nethtml.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case nethtml.ElementNode:
+			switch {
+			case droppedElements[c.Data]:
+				n.RemoveChild(c)
+			case allowedElements[c.Data] && c.Namespace == "":
+				c.Attr = sanitizeAttrs(c.Data, c.Attr, base)
+				if (c.Data == "img" && attr(c, "src") == "") || isTrackingPixel(c) {
+					n.RemoveChild(c)
+					break
+				}
+				sanitizeChildren(c, base)
+			default:
+				// unknown wrappers like <font> or <center>: keep what's inside
+				sanitizeChildren(c, base)
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+				n.RemoveChild(c)
+			}
+		case nethtml.TextNode:
+			// &#27; decodes to a raw ESC, which would reach the terminal
+			c.Data = stripControlChars(c.Data)
+		default:
+			// comments and doctypes
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+func sanitizeAttrs(element string, attrs []nethtml.Attribute, base *url.URL) []nethtml.Attribute {
+	var kept []nethtml.Attribute
+	for _, attr := range attrs {
+		if attr.Namespace != "" || !allowedAttrs[element][attr.Key] {
+			continue
+		}
+		if urlAttrs[attr.Key] {
+			link, ok := safeURL(attr.Val, base)
+			if !ok {
+				continue
+			}
+			attr.Val = link
+		}
+		attr.Val = stripControlChars(attr.Val)
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// safeURL resolves raw against base and only lets through http(s), mailto and in-page links.
+func safeURL(raw string, base *url.URL) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	if base != nil && !strings.HasPrefix(raw, "#") {
+		u = base.ResolveReference(u)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto", "":
+		return u.String(), true
+	}
+	return "", false
+}
+
+// isTrackingPixel spots the 1x1 images feeds use to count readers.
+func isTrackingPixel(n *nethtml.Node) bool {
+	if n.Data != "img" {
+		return false
+	}
+	width, height := attr(n, "width"), attr(n, "height")
+	return (width == "0" || width == "1") && (height == "0" || height == "1")
+}
+
+// stripControlChars removes the C0 and C1 control characters except newline and tab, so
+// feed text can't move the cursor or send escape sequences to the terminal.
+func stripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sanitizeBatch is how many stored posts HandlerSanitize loads at a time.
+const sanitizeBatch = 500
+
+// HandlerSanitize runs the stored descriptions and contents through sanitizeHTML again.
+// Posts stored before sanitizing existed are only replaced when their feed sends them
+// again, which for older posts never happens.
+func HandlerSanitize(ctx context.Context, s *config.State, cmd Command) error {
+	var afterID uuid.UUID
+	checked, sanitized := 0, 0
+	for {
+		posts, err := s.Db.GetPostBodiesAfter(ctx, database.GetPostBodiesAfterParams{
+			AfterID: afterID,
+			Batch:   sanitizeBatch,
+		})
+		if err != nil {
+			return fmt.Errorf("error fetching posts: %w", err)
+		}
+
+		for _, post := range posts {
+			checked++
+			feedBase, _ := url.Parse(post.FeedUrl.String)
+			base := postBase(feedBase, post.Url)
+			description := sanitizeNullHTML(post.Description, base)
+			content := sanitizeNullHTML(post.Content, base)
+			if description == post.Description && content == post.Content {
+				continue
+			}
+			err = s.Db.UpdatePostBody(ctx, database.UpdatePostBodyParams{
+				ID:          post.ID,
+				Description: description,
+				Content:     content,
+			})
+			if err != nil {
+				return fmt.Errorf("error updating post %s: %w", post.Url, err)
+			}
+			sanitized++
+		}
+
+		if len(posts) < sanitizeBatch {
+			break
+		}
+		afterID = posts[len(posts)-1].ID
+	}
+
+	fmt.Printf("Checked %d post(s), sanitized %d.\n", checked, sanitized)
+	return nil
+}
+
+func sanitizeNullHTML(src sql.NullString, base *url.URL) sql.NullString {
+	if !src.Valid {
+		return src
+	}
+	return sql.NullString{String: sanitizeHTML(src.String, base), Valid: true}
+}
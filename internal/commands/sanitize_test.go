@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	base, _ := url.Parse("http://site/posts/1")
+	tests := []struct {
+		name, src, want string
+	}{
+		{"allowed markup", `<p>Hi <b>there</b></p>`, `<p>Hi <b>there</b></p>`},
+		{"script", `<p>Hi</p><script>alert(1)</script>`, `<p>Hi</p>`},
+		{"style and form", `<style>p{}</style><form><input name="q"/></form>ok`, `ok`},
+		{"event handlers", `<p onclick="evil()" class="x">Hi</p>`, `<p>Hi</p>`},
+		{"unknown wrapper", `<font color="red">red</font>`, `red`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"relative link", `<a href="../2">next</a>`, `<a href="http://site/2">next</a>`},
+		{"in-page link", `<a href="#top">top</a>`, `<a href="#top">top</a>`},
+		{"relative image", `<img src="/a.png" alt="A"/>`, `<img src="http://site/a.png" alt="A"/>`},
+		{"tracking pixel", `<p>Hi<img src="http://t/p.gif" width="1" height="1"/></p>`, `<p>Hi</p>`},
+		{"image without src", `<img src="javascript:x" alt="A"/>`, ``},
+		{"comment", `Hi<!-- note -->`, `Hi`},
+		{"empty", `  `, ``},
+	}
+	for _, tt := range tests {
+		if got := sanitizeHTML(tt.src, base); got != tt.want {
+			t.Errorf("%s: sanitizeHTML(%q) = %q, want %q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeHTMLFeed(t *testing.T) {
+	var feed RSSFeed
+	feed.Channel.Item = []RSSItem{
+		{Link: "/posts/1", Description: `<a href="2">next</a>`},
+		{Description: `<img src="logo.png"/>`},
+	}
+	SanitizeHTML(&feed, "http://site/feed.xml")
+
+	if got := feed.Channel.Item[0].Description; got != `<a href="http://site/posts/2">next</a>` {
+		t.Errorf("item link base: got %q", got)
+	}
+	if got := feed.Channel.Item[1].Description; got != `<img src="http://site/logo.png"/>` {
+		t.Errorf("feed url base: got %q", got)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	src := `<p>See <a href="http://site/a">this</a> and <a href="http://site/a">that</a>.</p>` +
+		`<ul><li>one</li><li>two</li></ul><blockquote>quoted</blockquote>`
+	want := "See this[1] and that[1].\n\n* one\n* two\n\n> quoted\n\n[1] http://site/a"
+	if got := RenderHTML(src, 80); got != want {
+		t.Errorf("RenderHTML = %q, want %q", got, want)
+	}
+
+	if got := RenderHTML(`<p>one two three four five six</p>`, 20); got != "one two three four\nfive six" {
+		t.Errorf("wrapped = %q", got)
+	}
+}
+
+func TestSanitizeHTMLIsStable(t *testing.T) {
+	// gator sanitize only rewrites posts whose body changes, so clean html must stay as it is
+	base, _ := url.Parse("http://site/posts/1")
+	for _, src := range []string{
+		`<p>Tom &amp; Jerry &lt;3</p><img src="a.png" alt="A"/><br/>text`,
+		`<ul><li><a href="/x" title="&#34;q&#34;">x</a></li></ul><pre>  code  </pre>`,
+		"<p>café &nbsp; <q>quote</q></p>",
+	} {
+		once := sanitizeHTML(src, base)
+		if twice := sanitizeHTML(once, base); twice != once {
+			t.Errorf("sanitizeHTML is not stable:\n%q\n%q", once, twice)
+		}
+	}
+}
+
+func TestPostBase(t *testing.T) {
+	feedBase, _ := url.Parse("http://site/feed.xml")
+	tests := []struct {
+		postURL, want string
+	}{
+		{"http://other/post", "http://other/post"},
+		{"/posts/1", "http://site/posts/1"},
+		{"", "http://site/feed.xml"},
+	}
+	for _, tt := range tests {
+		if got := postBase(feedBase, tt.postURL); got.String() != tt.want {
+			t.Errorf("postBase(%q) = %v, want %s", tt.postURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Bounds for the per-feed refresh interval. Feeds without enough history to guess
+// how often they publish use defaultFetchInterval.
+const (
+	minFetchInterval     = 10 * time.Minute
+	maxFetchInterval     = 24 * time.Hour
+	defaultFetchInterval = time.Hour
+)
+
+// FeedHints are the publisher's own hints on how often the feed should be fetched.
+type FeedHints struct {
+	// TTL is the RSS <ttl>.
+	TTL time.Duration
+	// UpdateEvery comes from sy:updatePeriod divided by sy:updateFrequency.
+	UpdateEvery time.Duration
+	SkipHours   map[int]bool
+	SkipDays    map[time.Weekday]bool
+	// CacheLifetime comes from the Cache-Control or Expires response headers.
+	CacheLifetime time.Duration
+}
+
+// feedHints collects the hints from a parsed feed.
+func feedHints(feed *RSSFeed, cacheLifetime time.Duration) FeedHints {
+	hints := FeedHints{
+		CacheLifetime: cacheLifetime,
+		SkipHours:     map[int]bool{},
+		SkipDays:      map[time.Weekday]bool{},
+	}
+	if feed == nil {
+		return hints
+	}
+
+	if ttl, err := strconv.Atoi(strings.TrimSpace(feed.Channel.TTL)); err == nil && ttl > 0 {
+		hints.TTL = time.Duration(ttl) * time.Minute
+	}
+	hints.UpdateEvery = syndicationInterval(feed.Channel.UpdatePeriod, feed.Channel.UpdateFrequency)
+
+	for _, hour := range feed.Channel.SkipHours {
+		h, err := strconv.Atoi(strings.TrimSpace(hour))
+		if err == nil && h >= 0 && h <= 24 {
+			// some feeds use 1-24 instead of 0-23
+			hints.SkipHours[h%24] = true
+		}
+	}
+	for _, day := range feed.Channel.SkipDays {
+		if weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]; ok {
+			hints.SkipDays[weekday] = true
+		}
+	}
+	return hints
+}
+
+// params stores the publisher's hints on the feed, see storedFeedHints.
+func (hints FeedHints) params(feedID uuid.UUID) database.SetFeedRefreshHintsParams {
+	params := database.SetFeedRefreshHintsParams{
+		ID:                feedID,
+		HintTtlSeconds:    nullSeconds(hints.TTL),
+		HintUpdateSeconds: nullSeconds(hints.UpdateEvery),
+		HintSkipHours:     []int32{},
+		HintSkipDays:      []int32{},
+	}
+	for hour := range hints.SkipHours {
+		params.HintSkipHours = append(params.HintSkipHours, int32(hour))
+	}
+	for day := range hints.SkipDays {
+		params.HintSkipDays = append(params.HintSkipDays, int32(day))
+	}
+	slices.Sort(params.HintSkipHours)
+	slices.Sort(params.HintSkipDays)
+	return params
+}
+
+// storedFeedHints rebuilds the hints saved by the last full fetch of the feed.
+func storedFeedHints(feed database.Feed, cacheLifetime time.Duration) FeedHints {
+	hints := FeedHints{
+		TTL:           time.Duration(feed.HintTtlSeconds.Int32) * time.Second,
+		UpdateEvery:   time.Duration(feed.HintUpdateSeconds.Int32) * time.Second,
+		CacheLifetime: cacheLifetime,
+		SkipHours:     map[int]bool{},
+		SkipDays:      map[time.Weekday]bool{},
+	}
+	for _, hour := range feed.HintSkipHours {
+		hints.SkipHours[int(hour)] = true
+	}
+	for _, day := range feed.HintSkipDays {
+		hints.SkipDays[time.Weekday(day)] = true
+	}
+	return hints
+}
+
+func nullSeconds(d time.Duration) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(d.Seconds()), Valid: d > 0}
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// syndicationInterval turns the RSS syndication module hints into a duration. The
+// frequency defaults to 1 and the period to daily when only the frequency is given.
+func syndicationInterval(period, frequency string) time.Duration {
+	period = strings.ToLower(strings.TrimSpace(period))
+	frequency = strings.TrimSpace(frequency)
+	if period == "" && frequency == "" {
+		return 0
+	}
+
+	var base time.Duration
+	switch period {
+	case "hourly":
+		base = time.Hour
+	case "", "daily":
+		base = 24 * time.Hour
+	case "weekly":
+		base = 7 * 24 * time.Hour
+	case "monthly":
+		base = 30 * 24 * time.Hour
+	case "yearly":
+		base = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	n, err := strconv.Atoi(frequency)
+	if err != nil || n < 1 {
+		n = 1
+	}
+	return base / time.Duration(n)
+}
+
+// cacheLifetime reads how long the response may be cached from Cache-Control max-age,
+// falling back to Expires.
+func cacheLifetime(header http.Header, now time.Time) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	// measure against the server clock when we have it
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		now = date
+	}
+	if lifetime := expires.Sub(now); lifetime > 0 {
+		return lifetime
+	}
+	return 0
+}
+
+// fetchInterval picks how long to wait before fetching a feed again. It aims for two
+// fetches per post based on postGap, the average time between recent posts, and
+// never fetches more often than the publisher asks for.
+func fetchInterval(hints FeedHints, postGap time.Duration) time.Duration {
+	interval := defaultFetchInterval
+	if postGap > 0 {
+		interval = postGap / 2
+	}
+
+	interval = max(interval, hints.TTL, hints.UpdateEvery, hints.CacheLifetime)
+	return min(max(interval, minFetchInterval), maxFetchInterval)
+}
+
+// nextFetchTime adds the interval to now and moves the result out of any skipHours
+// or skipDays, which are in GMT.
+func nextFetchTime(now time.Time, interval time.Duration, hints FeedHints) time.Time {
+	next := now.Add(interval).UTC()
+	if len(hints.SkipHours) == 0 && len(hints.SkipDays) == 0 {
+		return next
+	}
+
+	// a week of hours is enough unless every slot is skipped, in which case we give up skipping
+	for i := 0; i < 7*24; i++ {
+		if !hints.SkipHours[next.Hour()] && !hints.SkipDays[next.Weekday()] {
+			return next
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return now.Add(interval).UTC()
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFeedHints(t *testing.T) {
+	var feed RSSFeed
+	feed.Channel.TTL = "90"
+	feed.Channel.UpdatePeriod = "hourly"
+	feed.Channel.UpdateFrequency = "2"
+	feed.Channel.SkipHours = []string{"0", "24", "13", "x"}
+	feed.Channel.SkipDays = []string{"Saturday", " sunday ", "Someday"}
+
+	hints := feedHints(&feed, time.Minute)
+	if hints.TTL != 90*time.Minute || hints.UpdateEvery != 30*time.Minute || hints.CacheLifetime != time.Minute {
+		t.Errorf("hints = %+v", hints)
+	}
+	if len(hints.SkipHours) != 2 || !hints.SkipHours[0] || !hints.SkipHours[13] {
+		t.Errorf("SkipHours = %v, want 0 and 13", hints.SkipHours)
+	}
+	if len(hints.SkipDays) != 2 || !hints.SkipDays[time.Saturday] || !hints.SkipDays[time.Sunday] {
+		t.Errorf("SkipDays = %v, want Saturday and Sunday", hints.SkipDays)
+	}
+
+	if hints := feedHints(nil, 0); hints.TTL != 0 || len(hints.SkipHours) != 0 {
+		t.Errorf("feedHints(nil) = %+v, want no hints", hints)
+	}
+}
+
+func TestSyndicationInterval(t *testing.T) {
+	tests := []struct {
+		period, frequency string
+		want              time.Duration
+	}{
+		{"", "", 0},
+		{"hourly", "", time.Hour},
+		{"daily", "4", 6 * time.Hour},
+		{"", "2", 12 * time.Hour},
+		{"weekly", "0", 7 * 24 * time.Hour},
+		{"sometimes", "1", 0},
+	}
+	for _, tt := range tests {
+		if got := syndicationInterval(tt.period, tt.frequency); got != tt.want {
+			t.Errorf("syndicationInterval(%q, %q) = %v, want %v", tt.period, tt.frequency, got, tt.want)
+		}
+	}
+}
+
+func TestCacheLifetime(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{}, 0},
+		{http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute},
+		{http.Header{"Cache-Control": {"no-cache, max-age=600"}}, 0},
+		{http.Header{"Expires": {"Tue, 05 Mar 2024 13:00:00 GMT"}}, time.Hour},
+		// the server's Date wins over our clock
+		{http.Header{"Expires": {"Tue, 05 Mar 2024 13:00:00 GMT"}, "Date": {"Tue, 05 Mar 2024 12:30:00 GMT"}}, 30 * time.Minute},
+		{http.Header{"Expires": {"0"}}, 0},
+	}
+	for _, tt := range tests {
+		if got := cacheLifetime(tt.header, now); got != tt.want {
+			t.Errorf("cacheLifetime(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFetchInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		hints   FeedHints
+		postGap time.Duration
+		want    time.Duration
+	}{
+		{"no history", FeedHints{}, 0, defaultFetchInterval},
+		{"half the post gap", FeedHints{}, 6 * time.Hour, 3 * time.Hour},
+		{"ttl is longer", FeedHints{TTL: 5 * time.Hour}, 6 * time.Hour, 5 * time.Hour},
+		{"cache lifetime is longer", FeedHints{CacheLifetime: 2 * time.Hour}, time.Hour, 2 * time.Hour},
+		{"minimum", FeedHints{}, time.Minute, minFetchInterval},
+		{"maximum", FeedHints{UpdateEvery: 7 * 24 * time.Hour}, 0, maxFetchInterval},
+	}
+	for _, tt := range tests {
+		if got := fetchInterval(tt.hints, tt.postGap); got != tt.want {
+			t.Errorf("%s: fetchInterval = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNextFetchTime(t *testing.T) {
+	// a Friday
+	now := time.Date(2024, time.March, 8, 20, 30, 0, 0, time.UTC)
+
+	if got := nextFetchTime(now, time.Hour, FeedHints{}); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("without skips got %v", got)
+	}
+
+	hints := FeedHints{SkipHours: map[int]bool{21: true, 22: true}}
+	want := time.Date(2024, time.March, 8, 23, 0, 0, 0, time.UTC)
+	if got := nextFetchTime(now, time.Hour, hints); !got.Equal(want) {
+		t.Errorf("skipHours: got %v, want %v", got, want)
+	}
+
+	hints = FeedHints{SkipDays: map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}}
+	want = time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC)
+	if got := nextFetchTime(now, 4*time.Hour, hints); !got.Equal(want) {
+		t.Errorf("skipDays: got %v, want %v", got, want)
+	}
+
+	// every hour skipped: the hints are ignored
+	all := map[int]bool{}
+	for h := 0; h < 24; h++ {
+		all[h] = true
+	}
+	if got := nextFetchTime(now, time.Hour, FeedHints{SkipHours: all}); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("all hours skipped: got %v", got)
+	}
+}
@@ -0,0 +1,361 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boxy-pug/gator/internal/config"
+	"github.com/boxy-pug/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// ScrapeOptions controls how many feeds a single ScrapeFeeds call claims and how they're fetched.
+type ScrapeOptions struct {
+	Fetcher *Fetcher
+	Workers int
+	Batch   int
+	// Lease is how long a claim on a feed holds. It is renewed right before each fetch, so
+	// it only has to be longer than a single fetch can take.
+	Lease time.Duration
+	// MaxFailures is how many failed fetches in a row disable a feed.
+	MaxFailures int
+}
+
+// workerID identifies this process in feeds.claimed_by.
+var workerID = newWorkerID()
+
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()[:8])
+}
+
+// ScrapeStats counts what ScrapeFeeds did.
+type ScrapeStats struct {
+	Feeds        int
+	Failed       int
+	NewPosts     int
+	UpdatedPosts int
+}
+
+func (st *ScrapeStats) Add(other ScrapeStats) {
+	st.Feeds += other.Feeds
+	st.Failed += other.Failed
+	st.NewPosts += other.NewPosts
+	st.UpdatedPosts += other.UpdatedPosts
+}
+
+type scrapeResult struct {
+	stats ScrapeStats
+	err   error
+}
+
+// ScrapeFeeds claims the next batch of feeds and scrapes them with a pool of workers.
+// Claims are atomic, so several agg processes can share the same database.
+// When ctx is cancelled, in-flight fetches are aborted, feeds that weren't started are
+// skipped and all claims are still released. The errors of all failed feeds are joined
+// into the returned error.
+func ScrapeFeeds(ctx context.Context, s *config.State, opts ScrapeOptions) (ScrapeStats, error) {
+	var stats ScrapeStats
+	claimed, err := s.Db.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		ClaimedBy:    sql.NullString{String: workerID, Valid: true},
+		LeaseSeconds: opts.Lease.Seconds(),
+		Batch:        int32(opts.Batch),
+	})
+	if err != nil {
+		return stats, fmt.Errorf("could not claim feeds; %w", err)
+	}
+
+	jobs := make(chan database.Feed, len(claimed))
+	results := make(chan scrapeResult, len(claimed))
+
+	for i := 0; i < min(opts.Workers, len(claimed)); i++ {
+		go func() {
+			for feed := range jobs {
+				var result scrapeResult
+				// the feed may have waited behind the rest of the batch, so the lease is
+				// renewed for the fetch; if it ran out and another process took the feed, skip it
+				renewed, err := s.Db.RenewFeedClaim(ctx, database.RenewFeedClaimParams{
+					ID:           feed.ID,
+					ClaimedBy:    sql.NullString{String: workerID, Valid: true},
+					LeaseSeconds: opts.Lease.Seconds(),
+				})
+				if err == nil && renewed == 0 {
+					log.Printf("Lost the claim on feed %s, another process is fetching it.", feed.Name)
+					results <- result
+					continue
+				}
+				if err != nil && ctx.Err() == nil {
+					result.err = fmt.Errorf("feed %s: could not renew claim: %w", feed.Name, err)
+				} else if ctx.Err() == nil {
+					var scraped database.Feed
+					scraped, result.stats, result.err = scrapeFeed(ctx, s, opts.Fetcher, feed)
+					// an interrupted scrape says nothing about the feed
+					if ctx.Err() == nil {
+						recordFeedHealth(ctx, s, scraped, result.err, opts.MaxFailures)
+					}
+					if errors.Is(result.err, ErrHostThrottled) {
+						result.err = nil
+					}
+					if result.err != nil {
+						result.err = fmt.Errorf("feed %s: %w", feed.Name, result.err)
+					}
+				}
+				// after a merge the claim went away with the feed; the surviving feed's
+				// claim, if any, belongs to whoever is fetching it
+				releaseFeedClaim(ctx, s, feed)
+				results <- result
+			}
+		}()
+	}
+
+	for _, feed := range claimed {
+		jobs <- feed
+	}
+	close(jobs)
+
+	var errs []error
+	for range claimed {
+		result := <-results
+		stats.Add(result.stats)
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+	}
+
+	return stats, errors.Join(errs...)
+}
+
+// releaseFeedClaim lets other processes fetch the feed again. It has to happen even when
+// we're shutting down.
+func releaseFeedClaim(ctx context.Context, s *config.State, feed database.Feed) {
+	err := s.Db.ReleaseFeedClaim(context.WithoutCancel(ctx), database.ReleaseFeedClaimParams{
+		ID:        feed.ID,
+		ClaimedBy: sql.NullString{String: workerID, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error releasing claim on feed %s: %v", feed.Name, err)
+	}
+}
+
+// scrapeFeed fetches a single feed and stores its posts. If ctx is cancelled it stops
+// between posts; posts already written stay, and the feed is fetched in full next time.
+// It returns the feed the posts went to, which is a different one if a permanent
+// redirect merged this feed into it.
+func scrapeFeed(ctx context.Context, s *config.State, fetcher *Fetcher, nextFeed database.Feed) (database.Feed, ScrapeStats, error) {
+	stats := ScrapeStats{Feeds: 1}
+	fail := func(err error) (database.Feed, ScrapeStats, error) {
+		stats.Failed = 1
+		return nextFeed, stats, err
+	}
+
+	result, err := fetcher.FetchFeed(ctx, nextFeed.Url.String, nextFeed.Etag.String, nextFeed.LastModified.String)
+	if errors.Is(err, ErrHostThrottled) {
+		// never sent; recordFeedHealth reschedules it without counting a failure
+		return nextFeed, stats, err
+	}
+	if err != nil {
+		if result != nil {
+			if logErr := logFetch(ctx, s, nextFeed.ID, result); logErr != nil {
+				log.Printf("Error logging fetch of feed %s: %v", nextFeed.Name, logErr)
+			}
+		}
+		if ctx.Err() == nil {
+			handleFetchError(ctx, s, nextFeed, err)
+		}
+		return fail(fmt.Errorf("could not fetch feed content:%w", err))
+	}
+
+	if result.PermanentURL != "" && result.PermanentURL != nextFeed.Url.String {
+		moved, err := moveFeed(ctx, s, nextFeed, result.PermanentURL)
+		if err != nil {
+			return fail(fmt.Errorf("could not follow permanent redirect: %w", err))
+		}
+		nextFeed = moved
+	}
+
+	err = s.Db.ResetFeedNotFound(ctx, nextFeed.ID)
+	if err != nil {
+		return fail(fmt.Errorf("could not reset not found count: %w", err))
+	}
+	err = logFetch(ctx, s, nextFeed.ID, result)
+	if err != nil {
+		return fail(err)
+	}
+	if result.NotModified {
+		log.Printf("Feed %s not modified since last fetch.", nextFeed.Name)
+		if err := scheduleNextFetch(ctx, s, nextFeed, result); err != nil {
+			return fail(err)
+		}
+		return nextFeed, stats, nil
+	}
+
+	for _, item := range result.Feed.Channel.Item {
+		if ctx.Err() != nil {
+			return fail(fmt.Errorf("stopped saving posts: %w", ctx.Err()))
+		}
+		if item.PostGUID() == "" {
+			log.Printf("Post %s has no guid or link. Skipping.", item.Title)
+			continue
+		}
+
+		now := time.Now()
+		publishedAt, err := ParsePublishedDate(item.PubDate)
+		publishedAtInferred := err != nil
+		if publishedAtInferred {
+			log.Printf("error parsing published date for %s (%v), using current time instead", item.Title, err)
+			publishedAt = now
+		}
+		postID := uuid.New()
+		post := database.CreatePostParams{
+			ID:                  postID,
+			CreatedAt:           now,
+			UpdatedAt:           now,
+			Title:               item.Title,
+			Url:                 item.PostURL(),
+			Description:         sql.NullString{String: item.Description, Valid: item.Description != ""},
+			PublishedAt:         sql.NullTime{Time: publishedAt, Valid: true},
+			FeedID:              uuid.NullUUID{UUID: nextFeed.ID, Valid: true},
+			PublishedAtInferred: publishedAtInferred,
+			Guid:                item.PostGUID(),
+			ContentHash:         ContentHash(item.Title, item.Description, item.Content),
+			Content:             sql.NullString{String: item.Content, Valid: item.Content != ""},
+			Author:              sql.NullString{String: item.PostAuthor(), Valid: item.PostAuthor() != ""},
+			Categories:          item.PostCategories(),
+		}
+
+		saved, err := s.Db.CreatePost(ctx, post)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error saving post %s: %v", item.Title, err)
+			continue
+		}
+		// enclosures can change without the post changing, so they are saved either way
+		for _, enclosure := range item.enclosureParams(nextFeed.ID, now) {
+			if err := s.Db.SaveEnclosure(ctx, enclosure); err != nil {
+				log.Printf("Error saving enclosure %s of post %s: %v", enclosure.Url, item.Title, err)
+			}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			// the post exists and nothing changed
+			continue
+		}
+		if saved.ID != postID {
+			log.Printf("Post %s changed, updated it (revision %d).", post.Guid, saved.Revisions)
+			stats.UpdatedPosts++
+		} else {
+			stats.NewPosts++
+		}
+	}
+
+	// only remember the validators once the posts are stored, otherwise a 304 could hide posts we never saved
+	err = s.Db.SetFeedCacheValidators(ctx, database.SetFeedCacheValidatorsParams{
+		ID:           nextFeed.ID,
+		Etag:         sql.NullString{String: result.ETag, Valid: result.ETag != ""},
+		LastModified: sql.NullString{String: result.LastModified, Valid: result.LastModified != ""},
+	})
+	if err != nil {
+		return fail(fmt.Errorf("could not save cache validators: %w", err))
+	}
+	warning := strings.Join(result.Feed.Warnings, "; ")
+	if warning != "" {
+		log.Printf("Feed %s parsed with warnings: %s", nextFeed.Name, warning)
+	}
+	err = s.Db.SetFeedParseWarning(ctx, database.SetFeedParseWarningParams{
+		ID:           nextFeed.ID,
+		ParseWarning: sql.NullString{String: warning, Valid: warning != ""},
+	})
+	if err != nil {
+		return fail(fmt.Errorf("could not save parse warning: %w", err))
+	}
+	if err := scheduleNextFetch(ctx, s, nextFeed, result); err != nil {
+		return fail(err)
+	}
+	return nextFeed, stats, nil
+}
+
+// logFetch records the status and bytes of a fetch for gator stats.
+func logFetch(ctx context.Context, s *config.State, feedID uuid.UUID, result *FetchResult) error {
+	err := s.Db.CreateFetchLog(ctx, database.CreateFetchLogParams{
+		ID:               uuid.New(),
+		FetchedAt:        time.Now(),
+		FeedID:           feedID,
+		StatusCode:       int32(result.StatusCode),
+		ContentEncoding:  result.ContentEncoding,
+		BytesTransferred: result.BytesTransferred,
+		BytesDecoded:     result.BytesDecoded,
+	})
+	if err != nil {
+		return fmt.Errorf("could not log fetch: %w", err)
+	}
+	return nil
+}
+
+// scheduleNextFetch sets next_fetch_at from how often the feed publishes and its refresh hints.
+func scheduleNextFetch(ctx context.Context, s *config.State, feed database.Feed, result *FetchResult) error {
+	gapSeconds, err := s.Db.GetFeedPostGap(ctx, uuid.NullUUID{UUID: feed.ID, Valid: true})
+	if err != nil {
+		return fmt.Errorf("could not compute post frequency: %w", err)
+	}
+
+	// a 304 has no feed to read the hints from, so the ones from the last full fetch are used
+	var hints FeedHints
+	if result.Feed != nil {
+		hints = feedHints(result.Feed, result.CacheLifetime)
+		err = s.Db.SetFeedRefreshHints(ctx, hints.params(feed.ID))
+		if err != nil {
+			return fmt.Errorf("could not save refresh hints: %w", err)
+		}
+	} else {
+		hints = storedFeedHints(feed, result.CacheLifetime)
+	}
+	interval := fetchInterval(hints, time.Duration(gapSeconds*float64(time.Second)))
+	now := time.Now()
+	next := nextFetchTime(now, interval, hints)
+
+	err = s.Db.SetFeedNextFetch(ctx, database.SetFeedNextFetchParams{
+		ID:           feed.ID,
+		DelaySeconds: next.Sub(now).Seconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not schedule next fetch: %w", err)
+	}
+	return nil
+}
+
+// handleFetchError moves feeds that are gone, or keep answering 404, out of the rotation
+// and explains oversized feeds.
+func handleFetchError(ctx context.Context, s *config.State, feed database.Feed, err error) {
+	switch {
+	case errors.Is(err, ErrFeedTooLarge):
+		log.Printf("Feed %s is larger than fetch_max_response_bytes, skipped it.", feed.Name)
+	case errors.Is(err, ErrFeedGone):
+		log.Printf("Feed %s is gone, no longer fetching it.", feed.Name)
+		err = s.Db.SetFeedStatus(ctx, database.SetFeedStatusParams{
+			ID:     feed.ID,
+			Status: FeedStatusGone,
+		})
+		if err != nil {
+			log.Printf("Error updating status of feed %s: %v", feed.Name, err)
+		}
+	case errors.Is(err, ErrFeedNotFound):
+		count, err := s.Db.RecordFeedNotFound(ctx, database.RecordFeedNotFoundParams{
+			ID:          feed.ID,
+			MaxNotFound: maxNotFound,
+		})
+		if err != nil {
+			log.Printf("Error recording 404 for feed %s: %v", feed.Name, err)
+			return
+		}
+		if count >= maxNotFound {
+			log.Printf("Feed %s returned 404 %d times in a row, no longer fetching it.", feed.Name, count)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/boxy-pug/gator/internal/config"
+)
+
+// HandlerStats shows how much agg has downloaded according to the fetch log, and the
+// --top N feeds that cost the most bandwidth.
+func HandlerStats(ctx context.Context, s *config.State, cmd Command) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	top := flags.Int("top", 10, "number of most expensive feeds to list")
+	err := flags.Parse(cmd.Args)
+	if err != nil {
+		return err
+	}
+
+	totals, err := s.Db.GetFetchTotals(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching fetch totals: %w", err)
+	}
+	if totals.Fetches == 0 {
+		fmt.Println("No fetches logged yet, run agg first.")
+		return nil
+	}
+
+	fmt.Printf("Fetches: %d (%d not modified, %d compressed, %d HTTP errors)\n", totals.Fetches, totals.NotModified, totals.Compressed, totals.HttpErrors)
+	fmt.Printf("Transferred: %s\n", formatBytes(totals.BytesTransferred))
+	fmt.Printf("Decompressed: %s\n", formatBytes(totals.BytesDecoded))
+	if totals.OkBytesDecoded > 0 {
+		saved := 100 * float64(totals.OkBytesDecoded-totals.OkBytesTransferred) / float64(totals.OkBytesDecoded)
+		fmt.Printf("Saved by compression: %.1f%%\n", saved)
+	}
+
+	if *top <= 0 {
+		return nil
+	}
+	feeds, err := s.Db.GetFeedFetchCosts(ctx, int32(*top))
+	if err != nil {
+		return fmt.Errorf("error fetching feed fetch costs: %w", err)
+	}
+	fmt.Println()
+	fmt.Println("Most expensive feeds:")
+	for _, feed := range feeds {
+		fmt.Printf("* %s (%s)\n", feed.Name, feed.Url.String)
+		fmt.Printf("  %d fetches, %s transferred, %s decompressed\n", feed.Fetches, formatBytes(feed.BytesTransferred), formatBytes(feed.BytesDecoded))
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
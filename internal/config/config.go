@@ -1,6 +1,7 @@
 package config
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -16,10 +17,27 @@ const (
 type Config struct {
 	DbUrl           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	// Per-host politeness for agg. Zero values mean the defaults.
+	HostConcurrency     int    `json:"host_concurrency,omitempty"`
+	HostRequestInterval string `json:"host_request_interval,omitempty"`
+	// HTTP client settings for agg. Durations use Go syntax ("10s"), zero values mean the defaults.
+	FetchConnectTimeout   string `json:"fetch_connect_timeout,omitempty"`
+	FetchReadTimeout      string `json:"fetch_read_timeout,omitempty"`
+	FetchTotalTimeout     string `json:"fetch_total_timeout,omitempty"`
+	FetchMaxResponseBytes int64  `json:"fetch_max_response_bytes,omitempty"`
+	FetchMaxRedirects     int    `json:"fetch_max_redirects,omitempty"`
+	FetchProxy            string `json:"fetch_proxy,omitempty"`
+	UserAgent             string `json:"user_agent,omitempty"`
+	// Enclosure downloads. DownloadKeep is how many episodes per feed are kept unless the
+	// feed sets its own; zero values mean the defaults.
+	DownloadDir  string `json:"download_dir,omitempty"`
+	DownloadKeep int    `json:"download_keep,omitempty"`
 }
 
 type State struct {
-	Db     *database.Queries
+	Db *database.Queries
+	// DbConn is the connection Db runs on, for queries that need a transaction.
+	DbConn *sql.DB
 	Config *Config
 }
 
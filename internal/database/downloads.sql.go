@@ -0,0 +1,335 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: downloads.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const completeDownload = `-- name: CompleteDownload :exec
+UPDATE downloads
+SET status = 'complete', bytes_downloaded = $2, size = $2, sha256 = $3, etag = $4, error = NULL,
+    completed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+type CompleteDownloadParams struct {
+	ID              uuid.UUID
+	BytesDownloaded int64
+	Sha256          sql.NullString
+	Etag            sql.NullString
+}
+
+func (q *Queries) CompleteDownload(ctx context.Context, arg CompleteDownloadParams) error {
+	_, err := q.db.ExecContext(ctx, completeDownload,
+		arg.ID,
+		arg.BytesDownloaded,
+		arg.Sha256,
+		arg.Etag,
+	)
+	return err
+}
+
+const createDownload = `-- name: CreateDownload :one
+INSERT INTO downloads (id, created_at, updated_at, enclosure_id, path)
+VALUES ($1, $2, $2, $3, $4)
+RETURNING id, created_at, updated_at, enclosure_id, status, path, bytes_downloaded, size, sha256, etag, error, completed_at
+`
+
+type CreateDownloadParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	EnclosureID uuid.UUID
+	Path        string
+}
+
+func (q *Queries) CreateDownload(ctx context.Context, arg CreateDownloadParams) (Download, error) {
+	row := q.db.QueryRowContext(ctx, createDownload,
+		arg.ID,
+		arg.CreatedAt,
+		arg.EnclosureID,
+		arg.Path,
+	)
+	var i Download
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EnclosureID,
+		&i.Status,
+		&i.Path,
+		&i.BytesDownloaded,
+		&i.Size,
+		&i.Sha256,
+		&i.Etag,
+		&i.Error,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const downloadPathTaken = `-- name: DownloadPathTaken :one
+SELECT EXISTS (SELECT 1 FROM downloads WHERE path = $1)
+`
+
+// Whether another download already uses path, even if its file isn't there (yet).
+func (q *Queries) DownloadPathTaken(ctx context.Context, path string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, downloadPathTaken, path)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const failDownload = `-- name: FailDownload :exec
+UPDATE downloads
+SET status = 'failed', error = $2, bytes_downloaded = $3, size = $4, etag = $5, updated_at = NOW()
+WHERE id = $1
+`
+
+type FailDownloadParams struct {
+	ID              uuid.UUID
+	Error           sql.NullString
+	BytesDownloaded int64
+	Size            sql.NullInt64
+	Etag            sql.NullString
+}
+
+// Keeps what was downloaded so far, and the etag it came with, so the next run can resume.
+func (q *Queries) FailDownload(ctx context.Context, arg FailDownloadParams) error {
+	_, err := q.db.ExecContext(ctx, failDownload,
+		arg.ID,
+		arg.Error,
+		arg.BytesDownloaded,
+		arg.Size,
+		arg.Etag,
+	)
+	return err
+}
+
+const getDownloadsToPrune = `-- name: GetDownloadsToPrune :many
+WITH ranked AS (
+    SELECT enclosures.id,
+        COALESCE(feeds.download_keep, $1::int) AS keep,
+        DENSE_RANK() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY CASE WHEN posts.published_at_inferred THEN posts.created_at ELSE posts.published_at END DESC, posts.id
+        ) AS episode_rank
+    FROM enclosures
+    INNER JOIN posts ON posts.id = enclosures.post_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+)
+SELECT downloads.id, downloads.created_at, downloads.updated_at, downloads.enclosure_id, downloads.status, downloads.path, downloads.bytes_downloaded, downloads.size, downloads.sha256, downloads.etag, downloads.error, downloads.completed_at
+FROM downloads
+INNER JOIN ranked ON ranked.id = downloads.enclosure_id
+WHERE downloads.status <> 'deleted' AND ranked.episode_rank > ranked.keep
+`
+
+// Downloads of episodes that fell out of their feed's download_keep window.
+func (q *Queries) GetDownloadsToPrune(ctx context.Context, defaultKeep int32) ([]Download, error) {
+	rows, err := q.db.QueryContext(ctx, getDownloadsToPrune, defaultKeep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Download
+	for rows.Next() {
+		var i Download
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EnclosureID,
+			&i.Status,
+			&i.Path,
+			&i.BytesDownloaded,
+			&i.Size,
+			&i.Sha256,
+			&i.Etag,
+			&i.Error,
+			&i.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEnclosuresToDownload = `-- name: GetEnclosuresToDownload :many
+WITH ranked AS (
+    SELECT enclosures.id, enclosures.url, enclosures.mime_type, enclosures.length,
+        posts.title, posts.published_at, feeds.name AS feed_name,
+        COALESCE(feeds.download_keep, $1::int) AS keep,
+        DENSE_RANK() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY CASE WHEN posts.published_at_inferred THEN posts.created_at ELSE posts.published_at END DESC, posts.id
+        ) AS episode_rank
+    FROM enclosures
+    INNER JOIN posts ON posts.id = enclosures.post_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+    WHERE feed_follows.user_id = $2
+)
+SELECT ranked.id AS enclosure_id, ranked.url, ranked.mime_type, ranked.length, ranked.title,
+    ranked.published_at, ranked.feed_name,
+    downloads.id AS download_id, downloads.status, downloads.path, downloads.bytes_downloaded,
+    downloads.size, downloads.sha256, downloads.etag
+FROM ranked
+LEFT JOIN downloads ON downloads.enclosure_id = ranked.id
+WHERE ranked.episode_rank <= ranked.keep
+ORDER BY ranked.feed_name, ranked.episode_rank, ranked.url
+`
+
+type GetEnclosuresToDownloadParams struct {
+	DefaultKeep int32
+	UserID      uuid.NullUUID
+}
+
+type GetEnclosuresToDownloadRow struct {
+	EnclosureID     uuid.UUID
+	Url             string
+	MimeType        string
+	Length          sql.NullInt64
+	Title           string
+	PublishedAt     sql.NullTime
+	FeedName        string
+	DownloadID      uuid.NullUUID
+	Status          sql.NullString
+	Path            sql.NullString
+	BytesDownloaded sql.NullInt64
+	Size            sql.NullInt64
+	Sha256          sql.NullString
+	Etag            sql.NullString
+}
+
+// The newest download_keep episodes of every feed the user follows, with their download
+// if there is one. Posts with several enclosures count as one episode.
+func (q *Queries) GetEnclosuresToDownload(ctx context.Context, arg GetEnclosuresToDownloadParams) ([]GetEnclosuresToDownloadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresToDownload, arg.DefaultKeep, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetEnclosuresToDownloadRow
+	for rows.Next() {
+		var i GetEnclosuresToDownloadRow
+		if err := rows.Scan(
+			&i.EnclosureID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+			&i.Title,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.DownloadID,
+			&i.Status,
+			&i.Path,
+			&i.BytesDownloaded,
+			&i.Size,
+			&i.Sha256,
+			&i.Etag,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedDownloadPaths = `-- name: GetFeedDownloadPaths :many
+SELECT downloads.path
+FROM downloads
+JOIN enclosures ON enclosures.id = downloads.enclosure_id
+JOIN posts ON posts.id = enclosures.post_id
+WHERE posts.feed_id = $1
+`
+
+// Files of a feed's downloads, which have to be deleted from disk before the rows cascade away with the feed.
+func (q *Queries) GetFeedDownloadPaths(ctx context.Context, feedID uuid.NullUUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedDownloadPaths, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		items = append(items, path)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markDownloadDeleted = `-- name: MarkDownloadDeleted :exec
+UPDATE downloads
+SET status = 'deleted', updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkDownloadDeleted(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markDownloadDeleted, id)
+	return err
+}
+
+const setDownloadStatus = `-- name: SetDownloadStatus :exec
+UPDATE downloads
+SET status = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetDownloadStatusParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) SetDownloadStatus(ctx context.Context, arg SetDownloadStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setDownloadStatus, arg.ID, arg.Status)
+	return err
+}
+
+const setFeedDownloadKeep = `-- name: SetFeedDownloadKeep :one
+UPDATE feeds
+SET download_keep = $2, updated_at = NOW()
+WHERE feeds.url = $1 OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+RETURNING name
+`
+
+type SetFeedDownloadKeepParams struct {
+	Url          sql.NullString
+	DownloadKeep sql.NullInt32
+}
+
+// Also finds feeds by a url they were redirected away from.
+func (q *Queries) SetFeedDownloadKeep(ctx context.Context, arg SetFeedDownloadKeepParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, setFeedDownloadKeep, arg.Url, arg.DownloadKeep)
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
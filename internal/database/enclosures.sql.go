@@ -0,0 +1,117 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: enclosures.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getEnclosuresForPosts = `-- name: GetEnclosuresForPosts :many
+SELECT id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds, image_url, episode, season, transcript_url, chapters_url
+FROM enclosures
+WHERE post_id = ANY($1::uuid[])
+ORDER BY post_id, created_at, url
+`
+
+func (q *Queries) GetEnclosuresForPosts(ctx context.Context, postIds []uuid.UUID) ([]Enclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresForPosts, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Enclosure
+	for rows.Next() {
+		var i Enclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PostID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+			&i.DurationSeconds,
+			&i.ImageUrl,
+			&i.Episode,
+			&i.Season,
+			&i.TranscriptUrl,
+			&i.ChaptersUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const saveEnclosure = `-- name: SaveEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds, image_url, episode, season, transcript_url, chapters_url)
+SELECT $1, $2, $2, posts.id, $3, $4, $5,
+    $6, $7, $8, $9, $10, $11
+FROM posts
+WHERE posts.feed_id = $12 AND posts.guid = $13
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    image_url = EXCLUDED.image_url,
+    episode = EXCLUDED.episode,
+    season = EXCLUDED.season,
+    transcript_url = EXCLUDED.transcript_url,
+    chapters_url = EXCLUDED.chapters_url,
+    updated_at = EXCLUDED.updated_at
+WHERE (enclosures.mime_type, enclosures.length, enclosures.duration_seconds, enclosures.image_url,
+       enclosures.episode, enclosures.season, enclosures.transcript_url, enclosures.chapters_url)
+    IS DISTINCT FROM (EXCLUDED.mime_type, EXCLUDED.length, EXCLUDED.duration_seconds, EXCLUDED.image_url,
+       EXCLUDED.episode, EXCLUDED.season, EXCLUDED.transcript_url, EXCLUDED.chapters_url)
+`
+
+type SaveEnclosureParams struct {
+	ID              uuid.UUID
+	Now             time.Time
+	Url             string
+	MimeType        string
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+	ImageUrl        sql.NullString
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	TranscriptUrl   sql.NullString
+	ChaptersUrl     sql.NullString
+	FeedID          uuid.NullUUID
+	Guid            string
+}
+
+// Attaches an enclosure to the post with the given guid. Unchanged enclosures aren't touched.
+func (q *Queries) SaveEnclosure(ctx context.Context, arg SaveEnclosureParams) error {
+	_, err := q.db.ExecContext(ctx, saveEnclosure,
+		arg.ID,
+		arg.Now,
+		arg.Url,
+		arg.MimeType,
+		arg.Length,
+		arg.DurationSeconds,
+		arg.ImageUrl,
+		arg.Episode,
+		arg.Season,
+		arg.TranscriptUrl,
+		arg.ChaptersUrl,
+		arg.FeedID,
+		arg.Guid,
+	)
+	return err
+}
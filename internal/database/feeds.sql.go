@@ -11,8 +11,84 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    updated_at = NOW(),
+    claimed_by = $1,
+    claimed_until = NOW() + make_interval(secs => $2::float8)
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE status = 'active'
+    AND (claimed_until IS NULL OR claimed_until < NOW())
+    AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, status, not_found_count, claimed_by, claimed_until, last_error, last_error_at, consecutive_failures, last_success_at, next_fetch_at, parse_warning, download_keep, hint_ttl_seconds, hint_update_seconds, hint_skip_hours, hint_skip_days
+`
+
+type ClaimFeedsToFetchParams struct {
+	ClaimedBy    sql.NullString
+	LeaseSeconds float64
+	Batch        int32
+}
+
+// Claims the next feeds to fetch for one agg process. Rows locked by another process are
+// skipped and a claim only holds until claimed_until, so a crashed process can't keep feeds forever.
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.ClaimedBy, arg.LeaseSeconds, arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.Status,
+			&i.NotFoundCount,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastSuccessAt,
+			&i.NextFetchAt,
+			&i.ParseWarning,
+			&i.DownloadKeep,
+			&i.HintTtlSeconds,
+			&i.HintUpdateSeconds,
+			pq.Array(&i.HintSkipHours),
+			pq.Array(&i.HintSkipDays),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES (
@@ -23,7 +99,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, status, not_found_count, claimed_by, claimed_until, last_error, last_error_at, consecutive_failures, last_success_at, next_fetch_at, parse_warning, download_keep, hint_ttl_seconds, hint_update_seconds, hint_skip_hours, hint_skip_days
 `
 
 type CreateFeedParams struct {
@@ -53,10 +129,43 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.Status,
+		&i.NotFoundCount,
+		&i.ClaimedBy,
+		&i.ClaimedUntil,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.ParseWarning,
+		&i.DownloadKeep,
+		&i.HintTtlSeconds,
+		&i.HintUpdateSeconds,
+		pq.Array(&i.HintSkipHours),
+		pq.Array(&i.HintSkipDays),
 	)
 	return i, err
 }
 
+const createFeedAlias = `-- name: CreateFeedAlias :exec
+INSERT INTO feed_aliases (url, created_at, feed_id)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (url) DO UPDATE SET feed_id = EXCLUDED.feed_id
+`
+
+type CreateFeedAliasParams struct {
+	Url    string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) CreateFeedAlias(ctx context.Context, arg CreateFeedAliasParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedAlias, arg.Url, arg.FeedID)
+	return err
+}
+
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
     INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
@@ -112,22 +221,42 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 }
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_inferred, guid, content_hash, content, author, categories)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET url = EXCLUDED.url,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories,
+    content_hash = EXCLUDED.content_hash,
+    updated_at = EXCLUDED.updated_at,
+    revisions = posts.revisions + CASE WHEN posts.content_hash NOT IN ('', EXCLUDED.content_hash) THEN 1 ELSE 0 END
+WHERE posts.url <> EXCLUDED.url OR posts.content_hash <> EXCLUDED.content_hash
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_inferred, guid, content_hash, revisions, content, author, categories
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.NullUUID
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.NullUUID
+	PublishedAtInferred bool
+	Guid                string
+	ContentHash         string
+	Content             sql.NullString
+	Author              sql.NullString
+	Categories          []string
 }
 
+// A post is identified by its guid within a feed. Known posts are only touched when their url
+// or content changed, so no row is returned for unchanged posts. Rows from before content
+// hashes existed have an empty hash and don't count as a revision.
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
 	row := q.db.QueryRowContext(ctx, createPost,
 		arg.ID,
@@ -138,6 +267,12 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.PublishedAtInferred,
+		arg.Guid,
+		arg.ContentHash,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
 	)
 	var i Post
 	err := row.Scan(
@@ -149,15 +284,34 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.PublishedAtInferred,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Revisions,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const deleteFollowFeed = `-- name: DeleteFollowFeed :exec
 DELETE FROM feed_follows
-USING feeds
-WHERE feed_follows.feed_id = feeds.id
-AND feeds.url = $1
+WHERE feed_follows.feed_id IN (
+    SELECT id FROM feeds WHERE feeds.url = $1
+    UNION
+    SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1
+)
 AND feed_follows.user_id = $2
 `
 
@@ -171,10 +325,80 @@ func (q *Queries) DeleteFollowFeed(ctx context.Context, arg DeleteFollowFeedPara
 	return err
 }
 
+const disableFeed = `-- name: DisableFeed :one
+UPDATE feeds
+SET status = 'disabled', updated_at = NOW()
+WHERE feeds.url = $1 OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+RETURNING name
+`
+
+// Also finds feeds by a url they were redirected away from.
+func (q *Queries) DisableFeed(ctx context.Context, url sql.NullString) (string, error) {
+	row := q.db.QueryRowContext(ctx, disableFeed, url)
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+const enableFeed = `-- name: EnableFeed :one
+UPDATE feeds
+SET status = 'active', consecutive_failures = 0, not_found_count = 0, next_fetch_at = NULL, updated_at = NOW()
+WHERE feeds.url = $1 OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+RETURNING name
+`
+
+// Also finds feeds by a url they were redirected away from.
+func (q *Queries) EnableFeed(ctx context.Context, url sql.NullString) (string, error) {
+	row := q.db.QueryRowContext(ctx, enableFeed, url)
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+const getFeedById = `-- name: GetFeedById :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, status, not_found_count, claimed_by, claimed_until, last_error, last_error_at, consecutive_failures, last_success_at, next_fetch_at, parse_warning, download_keep, hint_ttl_seconds, hint_update_seconds, hint_skip_hours, hint_skip_days FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedById(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedById, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.Status,
+		&i.NotFoundCount,
+		&i.ClaimedBy,
+		&i.ClaimedUntil,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastSuccessAt,
+		&i.NextFetchAt,
+		&i.ParseWarning,
+		&i.DownloadKeep,
+		&i.HintTtlSeconds,
+		&i.HintUpdateSeconds,
+		pq.Array(&i.HintSkipHours),
+		pq.Array(&i.HintSkipDays),
+	)
+	return i, err
+}
+
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-SELECT id FROM feeds WHERE url = $1
+SELECT id FROM feeds WHERE feeds.url = $1
+UNION ALL
+SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1
+LIMIT 1
 `
 
+// Also finds feeds by a url they were redirected away from.
 func (q *Queries) GetFeedByUrl(ctx context.Context, url sql.NullString) (uuid.UUID, error) {
 	row := q.db.QueryRowContext(ctx, getFeedByUrl, url)
 	var id uuid.UUID
@@ -238,8 +462,80 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.NullUUI
 	return items, nil
 }
 
+const getFeedHealth = `-- name: GetFeedHealth :many
+SELECT name, url, status, last_fetched_at, last_success_at, last_error, last_error_at, consecutive_failures, next_fetch_at, parse_warning
+FROM feeds
+ORDER BY consecutive_failures DESC, name
+`
+
+type GetFeedHealthRow struct {
+	Name                string
+	Url                 sql.NullString
+	Status              string
+	LastFetchedAt       sql.NullTime
+	LastSuccessAt       sql.NullTime
+	LastError           sql.NullString
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int32
+	NextFetchAt         sql.NullTime
+	ParseWarning        sql.NullString
+}
+
+func (q *Queries) GetFeedHealth(ctx context.Context) ([]GetFeedHealthRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedHealth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedHealthRow
+	for rows.Next() {
+		var i GetFeedHealthRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.Status,
+			&i.LastFetchedAt,
+			&i.LastSuccessAt,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.ParseWarning,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedPostGap = `-- name: GetFeedPostGap :one
+SELECT COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)) / NULLIF(COUNT(*) - 1, 0), 0)::float8 AS avg_gap_seconds
+FROM (
+    SELECT published_at
+    FROM posts
+    WHERE feed_id = $1 AND NOT published_at_inferred AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT 20
+) AS recent
+`
+
+// Average time between the last 20 posts with a real published date, 0 if there aren't enough.
+func (q *Queries) GetFeedPostGap(ctx context.Context, feedID uuid.NullUUID) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPostGap, feedID)
+	var avg_gap_seconds float64
+	err := row.Scan(&avg_gap_seconds)
+	return avg_gap_seconds, err
+}
+
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, status, not_found_count, claimed_by, claimed_until, last_error, last_error_at, consecutive_failures, last_success_at, next_fetch_at, parse_warning, download_keep, hint_ttl_seconds, hint_update_seconds, hint_skip_hours, hint_skip_days FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -259,6 +555,23 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.Status,
+			&i.NotFoundCount,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastSuccessAt,
+			&i.NextFetchAt,
+			&i.ParseWarning,
+			&i.DownloadKeep,
+			&i.HintTtlSeconds,
+			&i.HintUpdateSeconds,
+			pq.Array(&i.HintSkipHours),
+			pq.Array(&i.HintSkipDays),
 		); err != nil {
 			return nil, err
 		}
@@ -273,34 +586,124 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
-FROM feeds
-ORDER BY last_fetched_at NULLS FIRST
+const getPostBodiesAfter = `-- name: GetPostBodiesAfter :many
+SELECT posts.id, posts.url, posts.description, posts.content, feeds.url AS feed_url
+FROM posts
+LEFT JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.id > $1
+ORDER BY posts.id
+LIMIT $2
+`
+
+type GetPostBodiesAfterParams struct {
+	AfterID uuid.UUID
+	Batch   int32
+}
+
+type GetPostBodiesAfterRow struct {
+	ID          uuid.UUID
+	Url         string
+	Description sql.NullString
+	Content     sql.NullString
+	FeedUrl     sql.NullString
+}
+
+// Posts in id order after after_id, for going through all of them in batches.
+func (q *Queries) GetPostBodiesAfter(ctx context.Context, arg GetPostBodiesAfterParams) ([]GetPostBodiesAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostBodiesAfter, arg.AfterID, arg.Batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostBodiesAfterRow
+	for rows.Next() {
+		var i GetPostBodiesAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Description,
+			&i.Content,
+			&i.FeedUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_inferred, posts.guid, posts.content_hash, posts.revisions, posts.content, posts.author, posts.categories, feeds.name AS feed_name
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = $1
+AND (posts.id::text = $2::text OR posts.url = $2::text)
+ORDER BY posts.created_at DESC
 LIMIT 1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
-	var i Feed
+type GetPostForUserParams struct {
+	UserID uuid.NullUUID
+	Post   string
+}
+
+type GetPostForUserRow struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.NullUUID
+	PublishedAtInferred bool
+	Guid                string
+	ContentHash         string
+	Revisions           int32
+	Content             sql.NullString
+	Author              sql.NullString
+	Categories          []string
+	FeedName            string
+}
+
+// A post by id or url, as long as the user follows its feed.
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (GetPostForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.UserID, arg.Post)
+	var i GetPostForUserRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.Name,
+		&i.Title,
 		&i.Url,
-		&i.UserID,
-		&i.LastFetchedAt,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.PublishedAtInferred,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Revisions,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.FeedName,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, title, url, description, published_at, posts.feed_id, feed_follows.id, feed_follows.created_at, feed_follows.updated_at, user_id, feed_follows.feed_id
+SELECT posts.id, posts.created_at, posts.updated_at, title, url, description, published_at, posts.feed_id, published_at_inferred, guid, content_hash, revisions, content, author, categories, feed_follows.id, feed_follows.created_at, feed_follows.updated_at, user_id, feed_follows.feed_id
 FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
 WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
+ORDER BY CASE WHEN posts.published_at_inferred THEN posts.created_at ELSE posts.published_at END DESC
 LIMIT $2
 `
 
@@ -310,21 +713,30 @@ type GetPostsForUserParams struct {
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.NullUUID
-	ID_2        uuid.UUID
-	CreatedAt_2 time.Time
-	UpdatedAt_2 time.Time
-	UserID      uuid.NullUUID
-	FeedID_2    uuid.NullUUID
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.NullUUID
+	PublishedAtInferred bool
+	Guid                string
+	ContentHash         string
+	Revisions           int32
+	Content             sql.NullString
+	Author              sql.NullString
+	Categories          []string
+	ID_2                uuid.UUID
+	CreatedAt_2         time.Time
+	UpdatedAt_2         time.Time
+	UserID              uuid.NullUUID
+	FeedID_2            uuid.NullUUID
 }
 
+// Guessed dates are just the time we first saw the post, so order those by created_at
+// to keep them from jumping around.
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
 	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
 	if err != nil {
@@ -343,6 +755,13 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.PublishedAtInferred,
+			&i.Guid,
+			&i.ContentHash,
+			&i.Revisions,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
 			&i.ID_2,
 			&i.CreatedAt_2,
 			&i.UpdatedAt_2,
@@ -362,17 +781,302 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	return items, nil
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
+const moveFeedAliases = `-- name: MoveFeedAliases :exec
+UPDATE feed_aliases
+SET feed_id = $1
+WHERE feed_id = $2
+`
+
+type MoveFeedAliasesParams struct {
+	TargetID uuid.UUID
+	SourceID uuid.UUID
+}
+
+func (q *Queries) MoveFeedAliases(ctx context.Context, arg MoveFeedAliasesParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedAliases, arg.TargetID, arg.SourceID)
+	return err
+}
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $1, updated_at = NOW()
+WHERE feed_follows.feed_id = $2
+AND feed_follows.user_id NOT IN (
+    SELECT user_id FROM feed_follows AS existing WHERE existing.feed_id = $1 AND existing.user_id IS NOT NULL
+)
+`
+
+type MoveFeedFollowsParams struct {
+	TargetID uuid.NullUUID
+	SourceID uuid.NullUUID
+}
+
+// Moves follows to another feed, except for users who already follow it.
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.TargetID, arg.SourceID)
+	return err
+}
+
+const moveFeedPosts = `-- name: MoveFeedPosts :exec
+UPDATE posts
+SET feed_id = $1
+WHERE posts.feed_id = $2
+AND posts.guid NOT IN (
+    SELECT guid FROM posts AS existing WHERE existing.feed_id = $1
+)
+`
+
+type MoveFeedPostsParams struct {
+	TargetID uuid.NullUUID
+	SourceID uuid.NullUUID
+}
+
+// Moves posts to another feed, except for ones it already has.
+func (q *Queries) MoveFeedPosts(ctx context.Context, arg MoveFeedPostsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedPosts, arg.TargetID, arg.SourceID)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+    last_error = $1,
+    last_error_at = NOW(),
+    next_fetch_at = NOW() + make_interval(secs => $2::float8),
+    status = CASE WHEN consecutive_failures + 1 >= $3::int THEN 'disabled' ELSE status END
+WHERE id = $4
+RETURNING consecutive_failures, status
+`
+
+type RecordFeedFailureParams struct {
+	LastError      sql.NullString
+	BackoffSeconds float64
+	MaxFailures    int32
+	ID             uuid.UUID
+}
+
+type RecordFeedFailureRow struct {
+	ConsecutiveFailures int32
+	Status              string
+}
+
+// Pushes the next attempt out by backoff_seconds and disables the feed once it
+// has failed max_failures times in a row.
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (RecordFeedFailureRow, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.BackoffSeconds,
+		arg.MaxFailures,
+		arg.ID,
+	)
+	var i RecordFeedFailureRow
+	err := row.Scan(&i.ConsecutiveFailures, &i.Status)
+	return i, err
+}
+
+const recordFeedNotFound = `-- name: RecordFeedNotFound :one
+UPDATE feeds
+SET not_found_count = not_found_count + 1,
+    status = CASE WHEN not_found_count + 1 >= $2::int THEN 'not_found' ELSE status END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING not_found_count
+`
+
+type RecordFeedNotFoundParams struct {
+	ID          uuid.UUID
+	MaxNotFound int32
+}
+
+// Counts a 404 and moves the feed to not_found once it has happened max_not_found times in a row.
+func (q *Queries) RecordFeedNotFound(ctx context.Context, arg RecordFeedNotFoundParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedNotFound, arg.ID, arg.MaxNotFound)
+	var not_found_count int32
+	err := row.Scan(&not_found_count)
+	return not_found_count, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0, last_success_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, id)
+	return err
+}
+
+const releaseFeedClaim = `-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET claimed_by = NULL, claimed_until = NULL
+WHERE id = $1 AND claimed_by = $2
+`
+
+type ReleaseFeedClaimParams struct {
+	ID        uuid.UUID
+	ClaimedBy sql.NullString
+}
+
+func (q *Queries) ReleaseFeedClaim(ctx context.Context, arg ReleaseFeedClaimParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedClaim, arg.ID, arg.ClaimedBy)
+	return err
+}
+
+const renewFeedClaim = `-- name: RenewFeedClaim :execrows
+UPDATE feeds
+SET claimed_until = NOW() + make_interval(secs => $1::float8)
+WHERE id = $2 AND claimed_by = $3
+`
+
+type RenewFeedClaimParams struct {
+	LeaseSeconds float64
+	ID           uuid.UUID
+	ClaimedBy    sql.NullString
+}
+
+// Extends a claim right before the feed is fetched, since it may have waited in the batch
+// for a while. No row is updated if the lease ran out and another process claimed the feed.
+func (q *Queries) RenewFeedClaim(ctx context.Context, arg RenewFeedClaimParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renewFeedClaim, arg.LeaseSeconds, arg.ID, arg.ClaimedBy)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resetFeedNotFound = `-- name: ResetFeedNotFound :exec
+UPDATE feeds
+SET not_found_count = 0
+WHERE id = $1 AND not_found_count > 0
+`
+
+func (q *Queries) ResetFeedNotFound(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetFeedNotFound, id)
+	return err
+}
+
+const setFeedCacheValidators = `-- name: SetFeedCacheValidators :exec
+UPDATE feeds
+SET etag = $2, last_modified = $3
+WHERE id = $1
+`
+
+type SetFeedCacheValidatorsParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) SetFeedCacheValidators(ctx context.Context, arg SetFeedCacheValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedCacheValidators, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const setFeedNextFetch = `-- name: SetFeedNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + make_interval(secs => $1::float8)
+WHERE id = $2
+`
+
+type SetFeedNextFetchParams struct {
+	DelaySeconds float64
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetFeedNextFetch(ctx context.Context, arg SetFeedNextFetchParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedNextFetch, arg.DelaySeconds, arg.ID)
+	return err
+}
+
+const setFeedParseWarning = `-- name: SetFeedParseWarning :exec
+UPDATE feeds
+SET parse_warning = $2
+WHERE id = $1
+`
+
+type SetFeedParseWarningParams struct {
+	ID           uuid.UUID
+	ParseWarning sql.NullString
+}
+
+// Cleared again by the next fetch that parses cleanly.
+func (q *Queries) SetFeedParseWarning(ctx context.Context, arg SetFeedParseWarningParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedParseWarning, arg.ID, arg.ParseWarning)
+	return err
+}
 
+const setFeedRefreshHints = `-- name: SetFeedRefreshHints :exec
 UPDATE feeds
-SET last_fetched_at = NOW(), updated_at = NOW()
+SET hint_ttl_seconds = $2, hint_update_seconds = $3, hint_skip_hours = $4, hint_skip_days = $5
 WHERE id = $1
 `
 
-// Add a MarkFeedFetched SQL query.
-// It should simply set the last_fetched_at and updated_at columns
-// to the current time for a given feed (probably by ID is simplest).
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
+type SetFeedRefreshHintsParams struct {
+	ID                uuid.UUID
+	HintTtlSeconds    sql.NullInt32
+	HintUpdateSeconds sql.NullInt32
+	HintSkipHours     []int32
+	HintSkipDays      []int32
+}
+
+func (q *Queries) SetFeedRefreshHints(ctx context.Context, arg SetFeedRefreshHintsParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRefreshHints,
+		arg.ID,
+		arg.HintTtlSeconds,
+		arg.HintUpdateSeconds,
+		pq.Array(arg.HintSkipHours),
+		pq.Array(arg.HintSkipDays),
+	)
+	return err
+}
+
+const setFeedStatus = `-- name: SetFeedStatus :exec
+UPDATE feeds
+SET status = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedStatusParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedStatus, arg.ID, arg.Status)
+	return err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedUrlParams struct {
+	ID  uuid.UUID
+	Url sql.NullString
+}
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedUrl, arg.ID, arg.Url)
+	return err
+}
+
+const updatePostBody = `-- name: UpdatePostBody :exec
+UPDATE posts
+SET description = $2, content = $3, content_hash = ''
+WHERE id = $1
+`
+
+type UpdatePostBodyParams struct {
+	ID          uuid.UUID
+	Description sql.NullString
+	Content     sql.NullString
+}
+
+// An empty hash lets the next fetch overwrite the post without counting a revision.
+func (q *Queries) UpdatePostBody(ctx context.Context, arg UpdatePostBodyParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostBody, arg.ID, arg.Description, arg.Content)
 	return err
 }
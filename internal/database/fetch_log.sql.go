@@ -0,0 +1,148 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.28.0
+// source: fetch_log.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFetchLog = `-- name: CreateFetchLog :exec
+INSERT INTO fetch_log (id, fetched_at, feed_id, status_code, content_encoding, bytes_transferred, bytes_decoded)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+`
+
+type CreateFetchLogParams struct {
+	ID               uuid.UUID
+	FetchedAt        time.Time
+	FeedID           uuid.UUID
+	StatusCode       int32
+	ContentEncoding  string
+	BytesTransferred int64
+	BytesDecoded     int64
+}
+
+func (q *Queries) CreateFetchLog(ctx context.Context, arg CreateFetchLogParams) error {
+	_, err := q.db.ExecContext(ctx, createFetchLog,
+		arg.ID,
+		arg.FetchedAt,
+		arg.FeedID,
+		arg.StatusCode,
+		arg.ContentEncoding,
+		arg.BytesTransferred,
+		arg.BytesDecoded,
+	)
+	return err
+}
+
+const getFeedFetchCosts = `-- name: GetFeedFetchCosts :many
+SELECT feeds.name, feeds.url,
+    COUNT(*) AS fetches,
+    SUM(fetch_log.bytes_transferred)::bigint AS bytes_transferred,
+    SUM(fetch_log.bytes_decoded)::bigint AS bytes_decoded
+FROM fetch_log
+INNER JOIN feeds ON feeds.id = fetch_log.feed_id
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY bytes_transferred DESC, feeds.name
+LIMIT $1
+`
+
+type GetFeedFetchCostsRow struct {
+	Name             string
+	Url              sql.NullString
+	Fetches          int64
+	BytesTransferred int64
+	BytesDecoded     int64
+}
+
+// The feeds that cost the most to fetch, by bytes over the wire.
+func (q *Queries) GetFeedFetchCosts(ctx context.Context, limit int32) ([]GetFeedFetchCostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFetchCosts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedFetchCostsRow
+	for rows.Next() {
+		var i GetFeedFetchCostsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.Fetches,
+			&i.BytesTransferred,
+			&i.BytesDecoded,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFetchTotals = `-- name: GetFetchTotals :one
+SELECT COUNT(*) AS fetches,
+    COUNT(*) FILTER (WHERE status_code = 304) AS not_modified,
+    COUNT(*) FILTER (WHERE status_code >= 400) AS http_errors,
+    COUNT(*) FILTER (WHERE content_encoding <> '') AS compressed,
+    COALESCE(SUM(bytes_transferred), 0)::bigint AS bytes_transferred,
+    COALESCE(SUM(bytes_decoded), 0)::bigint AS bytes_decoded,
+    -- error pages and 304s aren't feeds, so only successful fetches count towards the compression ratio
+    COALESCE(SUM(bytes_transferred) FILTER (WHERE status_code BETWEEN 200 AND 299), 0)::bigint AS ok_bytes_transferred,
+    COALESCE(SUM(bytes_decoded) FILTER (WHERE status_code BETWEEN 200 AND 299), 0)::bigint AS ok_bytes_decoded
+FROM fetch_log
+`
+
+type GetFetchTotalsRow struct {
+	Fetches            int64
+	NotModified        int64
+	HttpErrors         int64
+	Compressed         int64
+	BytesTransferred   int64
+	BytesDecoded       int64
+	OkBytesTransferred int64
+	OkBytesDecoded     int64
+}
+
+func (q *Queries) GetFetchTotals(ctx context.Context) (GetFetchTotalsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFetchTotals)
+	var i GetFetchTotalsRow
+	err := row.Scan(
+		&i.Fetches,
+		&i.NotModified,
+		&i.HttpErrors,
+		&i.Compressed,
+		&i.BytesTransferred,
+		&i.BytesDecoded,
+		&i.OkBytesTransferred,
+		&i.OkBytesDecoded,
+	)
+	return i, err
+}
+
+const moveFeedFetchLog = `-- name: MoveFeedFetchLog :exec
+UPDATE fetch_log
+SET feed_id = $1
+WHERE feed_id = $2
+`
+
+type MoveFeedFetchLogParams struct {
+	TargetID uuid.UUID
+	SourceID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFetchLog(ctx context.Context, arg MoveFeedFetchLogParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFetchLog, arg.TargetID, arg.SourceID)
+	return err
+}
@@ -11,14 +11,68 @@ import (
 	"github.com/google/uuid"
 )
 
+type Download struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EnclosureID     uuid.UUID
+	Status          string
+	Path            string
+	BytesDownloaded int64
+	Size            sql.NullInt64
+	Sha256          sql.NullString
+	Etag            sql.NullString
+	Error           sql.NullString
+	CompletedAt     sql.NullTime
+}
+
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        string
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+	ImageUrl        sql.NullString
+	Episode         sql.NullInt32
+	Season          sql.NullInt32
+	TranscriptUrl   sql.NullString
+	ChaptersUrl     sql.NullString
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           sql.NullString
-	UserID        uuid.NullUUID
-	LastFetchedAt sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 sql.NullString
+	UserID              uuid.NullUUID
+	LastFetchedAt       sql.NullTime
+	Etag                sql.NullString
+	LastModified        sql.NullString
+	Status              string
+	NotFoundCount       int32
+	ClaimedBy           sql.NullString
+	ClaimedUntil        sql.NullTime
+	LastError           sql.NullString
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int32
+	LastSuccessAt       sql.NullTime
+	NextFetchAt         sql.NullTime
+	ParseWarning        sql.NullString
+	DownloadKeep        sql.NullInt32
+	HintTtlSeconds      sql.NullInt32
+	HintUpdateSeconds   sql.NullInt32
+	HintSkipHours       []int32
+	HintSkipDays        []int32
+}
+
+type FeedAlias struct {
+	Url       string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
 }
 
 type FeedFollow struct {
@@ -29,15 +83,32 @@ type FeedFollow struct {
 	FeedID    uuid.NullUUID
 }
 
+type FetchLog struct {
+	ID               uuid.UUID
+	FetchedAt        time.Time
+	FeedID           uuid.UUID
+	StatusCode       int32
+	ContentEncoding  string
+	BytesTransferred int64
+	BytesDecoded     int64
+}
+
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.NullUUID
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.NullUUID
+	PublishedAtInferred bool
+	Guid                string
+	ContentHash         string
+	Revisions           int32
+	Content             sql.NullString
+	Author              sql.NullString
+	Categories          []string
 }
 
 type User struct {
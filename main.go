@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/boxy-pug/gator/internal/commands"
 	"github.com/boxy-pug/gator/internal/config"
@@ -29,6 +32,7 @@ func main() {
 	// Initialize state
 	appState := &config.State{
 		Db:     dbQueries,
+		DbConn: db,
 		Config: &c,
 	}
 
@@ -41,18 +45,33 @@ func main() {
 	cmds.Register("agg", commands.HandlerAgg)
 	cmds.Register("addfeed", commands.MiddleWareLoggedIn(commands.HandlerAddFeed))
 	cmds.Register("feeds", commands.HandlerFeeds)
+	cmds.Register("feedhealth", commands.HandlerFeedHealth)
+	cmds.Register("feed", commands.HandlerFeed)
+	cmds.Register("stats", commands.HandlerStats)
+	cmds.Register("sanitize", commands.HandlerSanitize)
 	cmds.Register("follow", commands.MiddleWareLoggedIn(commands.HandlerFollow))
 	cmds.Register("following", commands.MiddleWareLoggedIn(commands.HandlerFollowing))
 	cmds.Register("unfollow", commands.MiddleWareLoggedIn(commands.HandlerDeleteFeed))
 	cmds.Register("browse", commands.MiddleWareLoggedIn(commands.HandlerBrowse))
+	cmds.Register("read", commands.MiddleWareLoggedIn(commands.HandlerRead))
+	cmds.Register("download", commands.MiddleWareLoggedIn(commands.HandlerDownload))
 
 	//If there are fewer than 2 arguments, print an error message to the terminal and exit. Why two? The first argument is automatically the program name, which we ignore, and we require a command name.
 	if len(os.Args) < 2 {
 		log.Fatalf("command name required")
 	}
 
+	// Cancelled on Ctrl-C or a systemd stop, so long running commands like agg can wind down cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// restore the default handlers once we're shutting down, so a second Ctrl-C kills gator
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	cmd := commands.Command{Name: os.Args[1], Args: os.Args[2:]}
-	err = cmds.Run(appState, cmd)
+	err = cmds.Run(ctx, appState, cmd)
 
 	if err != nil {
 		fmt.Println("Error:", err)
@@ -0,0 +1,89 @@
+-- The newest download_keep episodes of every feed the user follows, with their download
+-- if there is one. Posts with several enclosures count as one episode.
+-- name: GetEnclosuresToDownload :many
+WITH ranked AS (
+    SELECT enclosures.id, enclosures.url, enclosures.mime_type, enclosures.length,
+        posts.title, posts.published_at, feeds.name AS feed_name,
+        COALESCE(feeds.download_keep, sqlc.arg(default_keep)::int) AS keep,
+        DENSE_RANK() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY CASE WHEN posts.published_at_inferred THEN posts.created_at ELSE posts.published_at END DESC, posts.id
+        ) AS episode_rank
+    FROM enclosures
+    INNER JOIN posts ON posts.id = enclosures.post_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+    INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+    WHERE feed_follows.user_id = sqlc.arg(user_id)
+)
+SELECT ranked.id AS enclosure_id, ranked.url, ranked.mime_type, ranked.length, ranked.title,
+    ranked.published_at, ranked.feed_name,
+    downloads.id AS download_id, downloads.status, downloads.path, downloads.bytes_downloaded,
+    downloads.size, downloads.sha256, downloads.etag
+FROM ranked
+LEFT JOIN downloads ON downloads.enclosure_id = ranked.id
+WHERE ranked.episode_rank <= ranked.keep
+ORDER BY ranked.feed_name, ranked.episode_rank, ranked.url;
+
+-- name: CreateDownload :one
+INSERT INTO downloads (id, created_at, updated_at, enclosure_id, path)
+VALUES ($1, $2, $2, $3, $4)
+RETURNING *;
+
+-- Whether another download already uses path, even if its file isn't there (yet).
+-- name: DownloadPathTaken :one
+SELECT EXISTS (SELECT 1 FROM downloads WHERE path = $1);
+
+-- name: SetDownloadStatus :exec
+UPDATE downloads
+SET status = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: CompleteDownload :exec
+UPDATE downloads
+SET status = 'complete', bytes_downloaded = $2, size = $2, sha256 = $3, etag = $4, error = NULL,
+    completed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- Keeps what was downloaded so far, and the etag it came with, so the next run can resume.
+-- name: FailDownload :exec
+UPDATE downloads
+SET status = 'failed', error = $2, bytes_downloaded = $3, size = $4, etag = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- Downloads of episodes that fell out of their feed's download_keep window.
+-- name: GetDownloadsToPrune :many
+WITH ranked AS (
+    SELECT enclosures.id,
+        COALESCE(feeds.download_keep, sqlc.arg(default_keep)::int) AS keep,
+        DENSE_RANK() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY CASE WHEN posts.published_at_inferred THEN posts.created_at ELSE posts.published_at END DESC, posts.id
+        ) AS episode_rank
+    FROM enclosures
+    INNER JOIN posts ON posts.id = enclosures.post_id
+    INNER JOIN feeds ON feeds.id = posts.feed_id
+)
+SELECT downloads.*
+FROM downloads
+INNER JOIN ranked ON ranked.id = downloads.enclosure_id
+WHERE downloads.status <> 'deleted' AND ranked.episode_rank > ranked.keep;
+
+-- name: MarkDownloadDeleted :exec
+UPDATE downloads
+SET status = 'deleted', updated_at = NOW()
+WHERE id = $1;
+
+-- Also finds feeds by a url they were redirected away from.
+-- name: SetFeedDownloadKeep :one
+UPDATE feeds
+SET download_keep = $2, updated_at = NOW()
+WHERE feeds.url = $1 OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+RETURNING name;
+
+-- Files of a feed's downloads, which have to be deleted from disk before the rows cascade away with the feed.
+-- name: GetFeedDownloadPaths :many
+SELECT downloads.path
+FROM downloads
+JOIN enclosures ON enclosures.id = downloads.enclosure_id
+JOIN posts ON posts.id = enclosures.post_id
+WHERE posts.feed_id = $1;
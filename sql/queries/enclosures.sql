@@ -0,0 +1,27 @@
+-- Attaches an enclosure to the post with the given guid. Unchanged enclosures aren't touched.
+-- name: SaveEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds, image_url, episode, season, transcript_url, chapters_url)
+SELECT sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), posts.id, sqlc.arg(url), sqlc.arg(mime_type), sqlc.arg(length),
+    sqlc.arg(duration_seconds), sqlc.arg(image_url), sqlc.arg(episode), sqlc.arg(season), sqlc.arg(transcript_url), sqlc.arg(chapters_url)
+FROM posts
+WHERE posts.feed_id = sqlc.arg(feed_id) AND posts.guid = sqlc.arg(guid)
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    image_url = EXCLUDED.image_url,
+    episode = EXCLUDED.episode,
+    season = EXCLUDED.season,
+    transcript_url = EXCLUDED.transcript_url,
+    chapters_url = EXCLUDED.chapters_url,
+    updated_at = EXCLUDED.updated_at
+WHERE (enclosures.mime_type, enclosures.length, enclosures.duration_seconds, enclosures.image_url,
+       enclosures.episode, enclosures.season, enclosures.transcript_url, enclosures.chapters_url)
+    IS DISTINCT FROM (EXCLUDED.mime_type, EXCLUDED.length, EXCLUDED.duration_seconds, EXCLUDED.image_url,
+       EXCLUDED.episode, EXCLUDED.season, EXCLUDED.transcript_url, EXCLUDED.chapters_url);
+
+-- name: GetEnclosuresForPosts :many
+SELECT *
+FROM enclosures
+WHERE post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY post_id, created_at, url;
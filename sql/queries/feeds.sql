@@ -27,8 +27,15 @@ FROM inserted_feed_follow
 INNER JOIN feeds ON feeds.id = inserted_feed_follow.feed_id
 INNER JOIN users ON users.id = inserted_feed_follow.user_id;
 
+-- Also finds feeds by a url they were redirected away from.
 -- name: GetFeedByUrl :one
-SELECT id FROM feeds WHERE url = $1;
+SELECT id FROM feeds WHERE feeds.url = $1
+UNION ALL
+SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1
+LIMIT 1;
+
+-- name: GetFeedById :one
+SELECT * FROM feeds WHERE id = $1;
 
 -- name: GetFeedFollowsForUser :many
 SELECT
@@ -46,29 +53,186 @@ WHERE feed_follows.user_id = $1;
 
 -- name: DeleteFollowFeed :exec
 DELETE FROM feed_follows
-USING feeds
-WHERE feed_follows.feed_id = feeds.id
-AND feeds.url = $1
+WHERE feed_follows.feed_id IN (
+    SELECT id FROM feeds WHERE feeds.url = $1
+    UNION
+    SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1
+)
 AND feed_follows.user_id = $2;
 
--- Add a MarkFeedFetched SQL query. 
--- It should simply set the last_fetched_at and updated_at columns 
--- to the current time for a given feed (probably by ID is simplest).
+-- Claims the next feeds to fetch for one agg process. Rows locked by another process are
+-- skipped and a claim only holds until claimed_until, so a crashed process can't keep feeds forever.
+-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    updated_at = NOW(),
+    claimed_by = sqlc.arg(claimed_by),
+    claimed_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE status = 'active'
+    AND (claimed_until IS NULL OR claimed_until < NOW())
+    AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+    LIMIT sqlc.arg(batch)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- Extends a claim right before the feed is fetched, since it may have waited in the batch
+-- for a while. No row is updated if the lease ran out and another process claimed the feed.
+-- name: RenewFeedClaim :execrows
+UPDATE feeds
+SET claimed_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+WHERE id = sqlc.arg(id) AND claimed_by = sqlc.arg(claimed_by);
+
+-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET claimed_by = NULL, claimed_until = NULL
+WHERE id = $1 AND claimed_by = $2;
 
--- name: MarkFeedFetched :exec
+-- name: UpdateFeedUrl :exec
 UPDATE feeds
-SET last_fetched_at = NOW(), updated_at = NOW()
+SET url = $2, updated_at = NOW()
 WHERE id = $1;
 
--- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+-- name: CreateFeedAlias :exec
+INSERT INTO feed_aliases (url, created_at, feed_id)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (url) DO UPDATE SET feed_id = EXCLUDED.feed_id;
+
+-- Moves follows to another feed, except for users who already follow it.
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(target_id), updated_at = NOW()
+WHERE feed_follows.feed_id = sqlc.arg(source_id)
+AND feed_follows.user_id NOT IN (
+    SELECT user_id FROM feed_follows AS existing WHERE existing.feed_id = sqlc.arg(target_id) AND existing.user_id IS NOT NULL
+);
+
+-- Moves posts to another feed, except for ones it already has.
+-- name: MoveFeedPosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(target_id)
+WHERE posts.feed_id = sqlc.arg(source_id)
+AND posts.guid NOT IN (
+    SELECT guid FROM posts AS existing WHERE existing.feed_id = sqlc.arg(target_id)
+);
+
+-- name: MoveFeedAliases :exec
+UPDATE feed_aliases
+SET feed_id = sqlc.arg(target_id)
+WHERE feed_id = sqlc.arg(source_id);
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: SetFeedCacheValidators :exec
+UPDATE feeds
+SET etag = $2, last_modified = $3
+WHERE id = $1;
+
+-- Cleared again by the next fetch that parses cleanly.
+-- name: SetFeedParseWarning :exec
+UPDATE feeds
+SET parse_warning = $2
+WHERE id = $1;
+
+-- name: SetFeedStatus :exec
+UPDATE feeds
+SET status = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- Counts a 404 and moves the feed to not_found once it has happened max_not_found times in a row.
+-- name: RecordFeedNotFound :one
+UPDATE feeds
+SET not_found_count = not_found_count + 1,
+    status = CASE WHEN not_found_count + 1 >= sqlc.arg(max_not_found)::int THEN 'not_found' ELSE status END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING not_found_count;
+
+-- name: ResetFeedNotFound :exec
+UPDATE feeds
+SET not_found_count = 0
+WHERE id = $1 AND not_found_count > 0;
+
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0, last_success_at = NOW()
+WHERE id = $1;
+
+-- name: SetFeedRefreshHints :exec
+UPDATE feeds
+SET hint_ttl_seconds = $2, hint_update_seconds = $3, hint_skip_hours = $4, hint_skip_days = $5
+WHERE id = $1;
+
+-- name: SetFeedNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + make_interval(secs => sqlc.arg(delay_seconds)::float8)
+WHERE id = sqlc.arg(id);
+
+-- Average time between the last 20 posts with a real published date, 0 if there aren't enough.
+-- name: GetFeedPostGap :one
+SELECT COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)) / NULLIF(COUNT(*) - 1, 0), 0)::float8 AS avg_gap_seconds
+FROM (
+    SELECT published_at
+    FROM posts
+    WHERE feed_id = $1 AND NOT published_at_inferred AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT 20
+) AS recent;
+
+-- Pushes the next attempt out by backoff_seconds and disables the feed once it
+-- has failed max_failures times in a row.
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+    last_error = sqlc.arg(last_error),
+    last_error_at = NOW(),
+    next_fetch_at = NOW() + make_interval(secs => sqlc.arg(backoff_seconds)::float8),
+    status = CASE WHEN consecutive_failures + 1 >= sqlc.arg(max_failures)::int THEN 'disabled' ELSE status END
+WHERE id = sqlc.arg(id)
+RETURNING consecutive_failures, status;
+
+-- Also finds feeds by a url they were redirected away from.
+-- name: EnableFeed :one
+UPDATE feeds
+SET status = 'active', consecutive_failures = 0, not_found_count = 0, next_fetch_at = NULL, updated_at = NOW()
+WHERE feeds.url = $1 OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+RETURNING name;
+
+-- Also finds feeds by a url they were redirected away from.
+-- name: DisableFeed :one
+UPDATE feeds
+SET status = 'disabled', updated_at = NOW()
+WHERE feeds.url = $1 OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+RETURNING name;
+
+-- name: GetFeedHealth :many
+SELECT name, url, status, last_fetched_at, last_success_at, last_error, last_error_at, consecutive_failures, next_fetch_at, parse_warning
 FROM feeds
-ORDER BY last_fetched_at NULLS FIRST
-LIMIT 1;
+ORDER BY consecutive_failures DESC, name;
 
+-- A post is identified by its guid within a feed. Known posts are only touched when their url
+-- or content changed, so no row is returned for unchanged posts. Rows from before content
+-- hashes existed have an empty hash and don't count as a revision.
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_inferred, guid, content_hash, content, author, categories)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET url = EXCLUDED.url,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories,
+    content_hash = EXCLUDED.content_hash,
+    updated_at = EXCLUDED.updated_at,
+    revisions = posts.revisions + CASE WHEN posts.content_hash NOT IN ('', EXCLUDED.content_hash) THEN 1 ELSE 0 END
+WHERE posts.url <> EXCLUDED.url OR posts.content_hash <> EXCLUDED.content_hash
 RETURNING *;
 
 -- name: GetPostsForUser :many
@@ -76,5 +240,33 @@ SELECT *
 FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
 WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
+-- Guessed dates are just the time we first saw the post, so order those by created_at
+-- to keep them from jumping around.
+ORDER BY CASE WHEN posts.published_at_inferred THEN posts.created_at ELSE posts.published_at END DESC
 LIMIT $2;
+
+-- A post by id or url, as long as the user follows its feed.
+-- name: GetPostForUser :one
+SELECT posts.*, feeds.name AS feed_name
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (posts.id::text = sqlc.arg(post)::text OR posts.url = sqlc.arg(post)::text)
+ORDER BY posts.created_at DESC
+LIMIT 1;
+
+-- Posts in id order after after_id, for going through all of them in batches.
+-- name: GetPostBodiesAfter :many
+SELECT posts.id, posts.url, posts.description, posts.content, feeds.url AS feed_url
+FROM posts
+LEFT JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.id > sqlc.arg(after_id)
+ORDER BY posts.id
+LIMIT sqlc.arg(batch);
+
+-- An empty hash lets the next fetch overwrite the post without counting a revision.
+-- name: UpdatePostBody :exec
+UPDATE posts
+SET description = $2, content = $3, content_hash = ''
+WHERE id = $1;
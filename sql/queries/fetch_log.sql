@@ -0,0 +1,32 @@
+-- name: CreateFetchLog :exec
+INSERT INTO fetch_log (id, fetched_at, feed_id, status_code, content_encoding, bytes_transferred, bytes_decoded)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: MoveFeedFetchLog :exec
+UPDATE fetch_log
+SET feed_id = sqlc.arg(target_id)
+WHERE feed_id = sqlc.arg(source_id);
+
+-- name: GetFetchTotals :one
+SELECT COUNT(*) AS fetches,
+    COUNT(*) FILTER (WHERE status_code = 304) AS not_modified,
+    COUNT(*) FILTER (WHERE status_code >= 400) AS http_errors,
+    COUNT(*) FILTER (WHERE content_encoding <> '') AS compressed,
+    COALESCE(SUM(bytes_transferred), 0)::bigint AS bytes_transferred,
+    COALESCE(SUM(bytes_decoded), 0)::bigint AS bytes_decoded,
+    -- error pages and 304s aren't feeds, so only successful fetches count towards the compression ratio
+    COALESCE(SUM(bytes_transferred) FILTER (WHERE status_code BETWEEN 200 AND 299), 0)::bigint AS ok_bytes_transferred,
+    COALESCE(SUM(bytes_decoded) FILTER (WHERE status_code BETWEEN 200 AND 299), 0)::bigint AS ok_bytes_decoded
+FROM fetch_log;
+
+-- The feeds that cost the most to fetch, by bytes over the wire.
+-- name: GetFeedFetchCosts :many
+SELECT feeds.name, feeds.url,
+    COUNT(*) AS fetches,
+    SUM(fetch_log.bytes_transferred)::bigint AS bytes_transferred,
+    SUM(fetch_log.bytes_decoded)::bigint AS bytes_decoded
+FROM fetch_log
+INNER JOIN feeds ON feeds.id = fetch_log.feed_id
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY bytes_transferred DESC, feeds.name
+LIMIT $1;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN guid TEXT;
+UPDATE posts SET guid = url;
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+
+-- +goose Down
+-- urls are only unique per guid now; keep the oldest post for each url so the old
+-- constraint can be restored. The other copies are lost.
+DELETE FROM posts p
+USING posts older
+WHERE p.url = older.url
+  AND (p.created_at, p.id) > (older.created_at, older.id);
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_guid_key;
+ALTER TABLE posts DROP COLUMN guid;
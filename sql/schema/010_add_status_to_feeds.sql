@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE feeds ADD COLUMN not_found_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN not_found_count;
+ALTER TABLE feeds DROP COLUMN status;
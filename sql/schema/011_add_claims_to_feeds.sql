@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN claimed_by TEXT NULL;
+ALTER TABLE feeds ADD COLUMN claimed_until TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN claimed_until;
+ALTER TABLE feeds DROP COLUMN claimed_by;
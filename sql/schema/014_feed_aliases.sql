@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE feed_aliases (
+    url TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_aliases;
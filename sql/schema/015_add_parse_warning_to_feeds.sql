@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN parse_warning TEXT NULL;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN parse_warning;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE fetch_log (
+    id UUID PRIMARY KEY,
+    fetched_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    status_code INTEGER NOT NULL,
+    content_encoding TEXT NOT NULL DEFAULT '',
+    bytes_transferred BIGINT NOT NULL,
+    bytes_decoded BIGINT NOT NULL
+);
+
+CREATE INDEX fetch_log_feed_id_idx ON fetch_log (feed_id);
+
+-- +goose Down
+DROP TABLE fetch_log;
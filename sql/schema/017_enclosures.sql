@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE enclosures (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    mime_type TEXT NOT NULL DEFAULT '',
+    length BIGINT NULL,
+    duration_seconds INTEGER NULL,
+    image_url TEXT NULL,
+    episode INTEGER NULL,
+    season INTEGER NULL,
+    transcript_url TEXT NULL,
+    chapters_url TEXT NULL,
+    UNIQUE (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE enclosures;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN download_keep INTEGER NULL;
+
+CREATE TABLE downloads (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    enclosure_id UUID NOT NULL UNIQUE REFERENCES enclosures(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    path TEXT NOT NULL,
+    bytes_downloaded BIGINT NOT NULL DEFAULT 0,
+    size BIGINT NULL,
+    sha256 TEXT NULL,
+    etag TEXT NULL,
+    error TEXT NULL,
+    completed_at TIMESTAMP NULL
+);
+
+-- +goose Down
+DROP TABLE downloads;
+ALTER TABLE feeds DROP COLUMN download_keep;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content TEXT NULL;
+ALTER TABLE posts ADD COLUMN author TEXT NULL;
+ALTER TABLE posts ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}';
+-- the content hash now covers the content; an empty hash lets the next fetch fill in
+-- the new columns without counting a revision
+UPDATE posts SET content_hash = '';
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN categories;
+ALTER TABLE posts DROP COLUMN author;
+ALTER TABLE posts DROP COLUMN content;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- descriptions and contents are stored sanitized now; an empty hash lets the next fetch
+-- replace them without counting a revision
+UPDATE posts SET content_hash = '';
+
+-- +goose Down
+-- nothing to undo, the hashes are filled in again by the next fetch
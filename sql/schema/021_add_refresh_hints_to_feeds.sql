@@ -0,0 +1,12 @@
+-- +goose Up
+-- the refresh hints of the last full fetch, so a 304 is scheduled with them too
+ALTER TABLE feeds ADD COLUMN hint_ttl_seconds INTEGER NULL;
+ALTER TABLE feeds ADD COLUMN hint_update_seconds INTEGER NULL;
+ALTER TABLE feeds ADD COLUMN hint_skip_hours INTEGER[] NOT NULL DEFAULT '{}';
+ALTER TABLE feeds ADD COLUMN hint_skip_days INTEGER[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN hint_skip_days;
+ALTER TABLE feeds DROP COLUMN hint_skip_hours;
+ALTER TABLE feeds DROP COLUMN hint_update_seconds;
+ALTER TABLE feeds DROP COLUMN hint_ttl_seconds;
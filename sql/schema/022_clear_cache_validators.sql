@@ -0,0 +1,7 @@
+-- +goose Up
+-- 019 and 020 wait for the next full fetch to fill in content and sanitize posts, but
+-- with the stored validators most servers answer 304 and that fetch never comes
+UPDATE feeds SET etag = NULL, last_modified = NULL;
+
+-- +goose Down
+-- nothing to undo, the validators are stored again by the next fetch